@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sirockin/openapi-extract-schema/pkg/extract"
+)
+
+// bundle writes a document split across files as a single file, with every
+// schema from another file in components.schemas
+func bundle(args []string) error {
+	flags := flag.NewFlagSet("bundle", flag.ExitOnError)
+	configFileName := flags.String("config", "", "read collision and comparison settings from the yaml configuration `file`")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: openapi-extract-schema bundle [flags] {input-file} {output-file}")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	opts, err := readOptions(*configFileName)
+	if err != nil {
+		return err
+	}
+	files, err := extract.ReadFiles(flags.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	doc, bundled := files.Bundle(opts)
+	for _, b := range bundled {
+		if b.Reused {
+			fmt.Printf("Reused %s for %s\n", b.Schema, b.Ref)
+		} else {
+			fmt.Printf("Added %s from %s\n", b.Schema, b.Ref)
+		}
+	}
+	return writeDocument(doc, flags.Arg(1), files.Format(files.Names()[0]))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sirockin/openapi-extract-schema/pkg/extract"
+)
+
+// dedupe replaces schemas of components.schemas which are the same as another
+// with references to a single one
+func dedupe(args []string) error {
+	flags := flag.NewFlagSet("dedupe", flag.ExitOnError)
+	configFileName := flags.String("config", "", "read comparison and canonical name settings from the yaml configuration `file`")
+	canonical := flags.String("canonical", "", "keep the schema of each group with the `rule`: shortest (the default), first or mostReferenced")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: openapi-extract-schema dedupe [flags] {input-file} {output-file}")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config, err := readConfig(*configFileName)
+	if err != nil {
+		return err
+	}
+	opts := config.DedupeOptions()
+	if *canonical != "" {
+		opts.Canonical = extract.CanonicalRule(*canonical)
+	}
+	doc, format, err := readDocument(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts.Log = os.Stdout
+	out, _, err := extract.Dedupe(doc, opts)
+	if err != nil {
+		return fmt.Errorf("deduplicating %s: %w", flags.Arg(0), err)
+	}
+	return writeDocument(out, flags.Arg(1), format)
+}
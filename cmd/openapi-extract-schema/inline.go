@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirockin/openapi-extract-schema/pkg/extract"
+)
+
+// stringsFlag is a flag which may be given more than once
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// inline moves schemas of components.schemas back to where they are
+// referenced, undoing extraction
+func inline(args []string) error {
+	flags := flag.NewFlagSet("inline", flag.ExitOnError)
+	var opts extract.InlineOptions
+	flags.Var((*stringsFlag)(&opts.Schemas), "schema", "inline the `name`d schema wherever it is referenced, rather than every schema referenced once (may be repeated)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: openapi-extract-schema inline [flags] {input-file} {output-file}")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	doc, format, err := readDocument(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts.Log = os.Stdout
+	out, _, err := extract.Inline(doc, opts)
+	if err != nil {
+		return fmt.Errorf("inlining %s: %w", flags.Arg(0), err)
+	}
+	return writeDocument(out, flags.Arg(1), format)
+}
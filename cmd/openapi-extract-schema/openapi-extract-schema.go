@@ -1,39 +1,212 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 
-	"github.com/sirockin/openapi-extract-schema/internal/spec"
+	"github.com/sirockin/openapi-extract-schema/pkg/extract"
 )
 
+// exitChanged is returned by --dry-run and --diff when the input is not
+// already fully extracted, so that they can be used to gate CI
+const exitChanged = 3
+
+var errChanged = errors.New("changes would be made")
+
+type config struct {
+	inputFileName  string
+	outputFileName string
+	reportFileName string
+	configFileName string
+	dryRun         bool
+	inPlace        bool
+	useOperationID bool
+	inlineArrays   bool
+	diff           bool
+}
+
+// commands are run by their name as the first argument, with the arguments
+// which follow it. Without one, the input is extracted.
+var commands = map[string]func(args []string) error{
+	"bundle": bundle,
+	"dedupe": dedupe,
+	"inline": inline,
+	"prune":  prune,
+}
+
 func main() {
-	if len(os.Args) != 3 {
-		fmt.Println("Usage: openapi-extract-schema {input-file} {output-file}")
-		return
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			exit(command(os.Args[2:]))
+		}
+	}
+
+	var c config
+	flag.StringVar(&c.configFileName, "config", "", "read naming templates from the yaml configuration `file`")
+	flag.BoolVar(&c.useOperationID, "use-operation-id", false, "name request and response bodies by the operationId of their operation")
+	flag.BoolVar(&c.inlineArrays, "inline-arrays", false, "leave request and response bodies which are arrays inline, extracting only their items")
+	flag.StringVar(&c.reportFileName, "report", "", "write a JSON report of each extraction to `file`")
+	flag.BoolVar(&c.inPlace, "in-place", false, "write each file of a document split across files back in place, rather than bundling them into one output")
+	flag.BoolVar(&c.dryRun, "dry-run", false, "write nothing, and exit with status 3 if changes would be made")
+	flag.BoolVar(&c.diff, "diff", false, "as --dry-run, and print the schemas which would be added and the references which would replace them")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: openapi-extract-schema [flags] {input-file} {output-file}")
+		fmt.Fprintln(flag.CommandLine.Output(), "       openapi-extract-schema --in-place|--dry-run|--diff [flags] {input-file}")
+		fmt.Fprintln(flag.CommandLine.Output(), "       openapi-extract-schema bundle [flags] {input-file} {output-file}")
+		fmt.Fprintln(flag.CommandLine.Output(), "       openapi-extract-schema inline [flags] {input-file} {output-file}")
+		fmt.Fprintln(flag.CommandLine.Output(), "       openapi-extract-schema prune [flags] {input-file} {output-file}")
+		fmt.Fprintln(flag.CommandLine.Output(), "       openapi-extract-schema dedupe [flags] {input-file} {output-file}")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	switch {
+	case flag.NArg() == 2 && !c.inPlace && !c.dryRun && !c.diff:
+		c.outputFileName = flag.Arg(1)
+	case flag.NArg() == 1 && (c.inPlace || c.dryRun || c.diff):
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	c.inputFileName = flag.Arg(0)
+	exit(run(c))
+}
+
+func exit(err error) {
+	if errors.Is(err, errChanged) {
+		os.Exit(exitChanged)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// readOptions returns the options given by the configuration file, if any
+func readOptions(configFileName string) (extract.Options, error) {
+	config, err := readConfig(configFileName)
+	return config.Options(), err
+}
+
+// readConfig reads the configuration file, if any
+func readConfig(configFileName string) (extract.Config, error) {
+	if configFileName == "" {
+		return extract.Config{}, nil
+	}
+	f, err := os.Open(configFileName)
+	if err != nil {
+		return extract.Config{}, err
 	}
+	defer f.Close()
+	config, err := extract.ReadConfig(f)
+	if err != nil {
+		return extract.Config{}, fmt.Errorf("reading %s: %w", configFileName, err)
+	}
+	return config, nil
+}
 
-	inputFileName := os.Args[1]
-	outputFileName := os.Args[2]
+// readDocument reads a single file, without the files it refers to, returning
+// its format
+func readDocument(name string) (*extract.Document, extract.Format, error) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, "", err
+	}
+	format := extract.FormatFromFileName(name)
+	if format == "" {
+		format = extract.FormatFromContent(content)
+	}
+	doc, err := extract.Read(bytes.NewReader(content), format)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading %s: %w", name, err)
+	}
+	return doc, format, nil
+}
 
-	inStream, err := os.Open(inputFileName)
+// writeDocument writes doc to the named file, in the format given by its name
+// or else inputFormat
+func writeDocument(doc *extract.Document, name string, inputFormat extract.Format) error {
+	format := extract.FormatFromFileName(name)
+	if format == "" {
+		format = inputFormat
+	}
+	// Only create the output once it is complete, so that a failure does not
+	// leave an empty output file behind
+	var out bytes.Buffer
+	err := doc.Write(&out, format)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	return os.WriteFile(name, out.Bytes(), 0o644)
+}
 
-	outStream, err := os.Create(outputFileName)
+func run(c config) error {
+	opts, err := readOptions(c.configFileName)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	inSpec, err := spec.NewFromYaml(inStream)
+	// Any files the input refers to are read too, and bundled into the
+	// output unless written back in place
+	files, err := extract.ReadFiles(c.inputFileName, opts)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	doc := files.Document()
 
-	outSpec := inSpec.Transform()
-	err = outSpec.ToYaml(outStream)
+	// Keep stdout for the diff
+	var log io.Writer = os.Stdout
+	if c.diff {
+		log = io.Discard
+	}
+	opts.Log = log
+	if c.useOperationID {
+		opts.UseOperationID = true
+	}
+	if c.inlineArrays {
+		opts.InlineArrays = true
+	}
+	result, err := extract.Transform(doc, opts)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("transforming %s: %w", c.inputFileName, err)
+	}
+
+	if c.reportFileName != "" {
+		var report bytes.Buffer
+		err = result.WriteReport(&report)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(c.reportFileName, report.Bytes(), 0o644)
+		if err != nil {
+			return err
+		}
 	}
+
+	if c.dryRun || c.diff {
+		if c.diff {
+			err = result.WriteDiff(os.Stdout)
+			if err != nil {
+				return err
+			}
+		}
+		if result.Changed() {
+			return errChanged
+		}
+		return nil
+	}
+
+	if c.inPlace {
+		written, err := files.Write(result.Document)
+		for _, name := range written {
+			fmt.Fprintf(log, "Wrote %s\n", name)
+		}
+		return err
+	}
+
+	return writeDocument(result.Document, c.outputFileName, files.Format(files.Names()[0]))
 }
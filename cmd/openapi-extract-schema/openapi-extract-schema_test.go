@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_dryRun(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want error
+	}{
+		"nothing to extract": {
+			in: `openapi: 3.0.0
+paths:
+  /pets:
+    get:
+      responses:
+        200:
+          description: ok
+`,
+		},
+		"inline schema": {
+			in: `openapi: 3.0.0
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+`,
+			want: errChanged,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "in.yaml")
+			output := filepath.Join(dir, "out.yaml")
+			assert.NoError(t, os.WriteFile(input, []byte(tt.in), 0o644))
+
+			assert.ErrorIs(t, run(config{inputFileName: input, dryRun: true}), tt.want)
+			assert.ErrorIs(t, run(config{inputFileName: input, diff: true}), tt.want)
+
+			// A real run agrees on whether the document changes
+			assert.NoError(t, run(config{inputFileName: input, outputFileName: output}))
+			out, err := os.ReadFile(output)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want == nil, string(out) == tt.in)
+		})
+	}
+}
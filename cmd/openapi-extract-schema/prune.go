@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sirockin/openapi-extract-schema/pkg/extract"
+)
+
+// prune removes the schemas of components.schemas which are not referenced
+func prune(args []string) error {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	var opts extract.PruneOptions
+	flags.Var((*stringsFlag)(&opts.Keep), "keep", "keep the `name`d schema even if it is not referenced (may be repeated)")
+	reportFileName := flags.String("report", "", "write a JSON report of the schemas removed to `file`")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: openapi-extract-schema prune [flags] {input-file} {output-file}")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	doc, format, err := readDocument(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts.Log = os.Stdout
+	out, removed, err := extract.Prune(doc, opts)
+	if err != nil {
+		return fmt.Errorf("pruning %s: %w", flags.Arg(0), err)
+	}
+	if *reportFileName != "" {
+		report := struct {
+			Removed []string `json:"removed"`
+		}{Removed: removed}
+		if report.Removed == nil {
+			report.Removed = []string{}
+		}
+		content, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*reportFileName, append(content, '\n'), 0o644); err != nil {
+			return err
+		}
+	}
+	return writeDocument(out, flags.Arg(1), format)
+}
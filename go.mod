@@ -3,13 +3,12 @@ module github.com/sirockin/openapi-extract-schema
 go 1.20
 
 require (
+	github.com/stretchr/testify v1.8.4
 	golang.org/x/text v0.11.0
-	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.8.4 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
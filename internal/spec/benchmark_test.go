@@ -0,0 +1,112 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// generateSpec returns a yaml document with n paths, each with a request and
+// responses containing nested inline objects, some of which are shared
+// between paths.
+func generateSpec(n int) string {
+	var sb strings.Builder
+	sb.WriteString("openapi: 3.0.0\npaths:\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, `  /resource%d/{id}:
+    post:
+      operationId: createResource%d
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name, owner]
+              properties:
+                name: {type: string}
+                count: {type: integer, minimum: %d}
+                owner:
+                  type: object
+                  properties:
+                    id: {type: string}
+                    address:
+                      type: object
+                      properties:
+                        street: {type: string}
+                        city: {type: string}
+                tags:
+                  type: array
+                  items:
+                    type: object
+                    properties:
+                      key: {type: string}
+                      value: {type: string}
+      responses:
+        200:
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  id: {type: string}
+                  created: {type: string, format: date-time}
+                  items:
+                    type: array
+                    items:
+                      type: object
+                      properties:
+                        index: {type: integer, maximum: %d}
+        404:
+          description: not found
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  message: {type: string}
+`, i, i, i, i)
+	}
+	sb.WriteString("components:\n  schemas:\n")
+	for i := 0; i < n/10; i++ {
+		fmt.Fprintf(&sb, `    Existing%d:
+      type: object
+      properties:
+        value: {type: integer, maximum: %d}
+`, i, i)
+	}
+	return sb.String()
+}
+
+func benchmarkTransform(b *testing.B, n int) {
+	s, err := NewFromYaml(strings.NewReader(generateSpec(n)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := s.Transform(Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTransform_100(b *testing.B)  { benchmarkTransform(b, 100) }
+func BenchmarkTransform_1000(b *testing.B) { benchmarkTransform(b, 1000) }
+
+func BenchmarkToYaml_1000(b *testing.B) {
+	s, err := NewFromYaml(strings.NewReader(generateSpec(1000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	out, _, err := s.Transform(Options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := out.ToYaml(&strings.Builder{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package spec
+
+// Bundled describes a schema from another file added to components.schemas
+// of the root document
+type Bundled struct {
+	// Schema is the name of the schema in components.schemas
+	Schema string `json:"schema"`
+	// Ref is the reference to the schema from the root document
+	Ref string `json:"ref"`
+	// Reused is set if an identical schema was already in components.schemas,
+	// so that no new schema was added
+	Reused bool `json:"reused,omitempty"`
+}
+
+// Bundle returns the merged document, in which every schema from another file
+// is in components.schemas and referenced by a local pointer. Schemas which
+// opts.Comparator considers the same as another are replaced by references to
+// it, preferring the schemas of the root document.
+func (f *FileSet) Bundle(opts Options) (Spec, []Bundled) {
+	ret := f.Merged.copy()
+	reused := ret.replaceDuplicates(opts.Comparator, func(names []string, refs map[string]int) string {
+		// Schemas of the root document first, then in sorted order
+		for _, name := range names {
+			if !f.added[name] {
+				return name
+			}
+		}
+		return names[0]
+	}, func(name string) bool {
+		return f.added[name]
+	})
+
+	var bundled []Bundled
+	for _, name := range sortedNames(f.added) {
+		b := Bundled{Schema: name, Ref: relativeRef(f.root, f.external[name])}
+		if match, ok := reused[name]; ok {
+			b.Schema, b.Reused = match, true
+		}
+		bundled = append(bundled, b)
+	}
+	return ret, bundled
+}
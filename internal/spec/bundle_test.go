@@ -0,0 +1,129 @@
+package spec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSet_Bundle(t *testing.T) {
+	files := map[string]string{
+		"openapi.yaml": `
+openapi: 3.0.0
+paths:
+  /pets:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: schemas/list.yaml
+  /owners:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: schemas/owner.yaml
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name: {type: string}
+    PetList:
+      type: array
+      items:
+        $ref: '#/components/schemas/Pet'
+`,
+		"schemas/list.yaml": `
+type: array
+items:
+  $ref: pet.yaml
+`,
+		"schemas/pet.yaml": `
+type: object
+properties:
+  name: {type: string}
+`,
+		"schemas/owner.yaml": `
+type: object
+properties:
+  pets:
+    $ref: list.yaml
+  animal:
+    oneOf:
+      - $ref: pet.yaml
+    discriminator:
+      propertyName: kind
+      mapping:
+        pet: pet.yaml
+`,
+	}
+	set, err := LoadFiles("openapi.yaml", parseFiles(files), Options{})
+	assert.NoError(t, err)
+	// Mapping references are restored on splitting
+	split, err := set.Split(set.Merged)
+	assert.NoError(t, err)
+	assert.Empty(t, split)
+
+	bundle, bundled := set.Bundle(Options{})
+	assert.Equal(t, []Bundled{
+		{Schema: "PetList", Ref: "schemas/list.yaml", Reused: true},
+		{Schema: "Owner", Ref: "schemas/owner.yaml"},
+		{Schema: "Pet", Ref: "schemas/pet.yaml", Reused: true},
+	}, bundled)
+
+	schemas, err := bundle.schemasNode()
+	assert.NoError(t, err)
+	assert.Len(t, schemas, 3)
+	get := func(pointer string) interface{} {
+		v, ok := lookupPointer(bundle.object, pointer)
+		assert.True(t, ok, pointer)
+		return v
+	}
+	assert.Equal(t, object{"$ref": "#/components/schemas/PetList"},
+		get("/paths/~1pets/get/responses/200/content/application~1json/schema"))
+	assert.Equal(t, object{"$ref": "#/components/schemas/PetList"}, get("/components/schemas/Owner/properties/pets"))
+	assert.Equal(t, object{"$ref": "#/components/schemas/Pet"}, get("/components/schemas/Owner/properties/animal/oneOf/0"))
+	assert.Equal(t, "#/components/schemas/Pet", get("/components/schemas/Owner/properties/animal/discriminator/mapping/pet"))
+}
+
+func TestLoadFiles_collisions(t *testing.T) {
+	files := map[string]string{
+		"openapi.yaml": `
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: v1/pet.yaml
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: v2/pet.yaml
+`,
+		"v1/pet.yaml": "type: object\nproperties:\n  name: {type: string}\n",
+		"v2/pet.yaml": "type: object\nproperties:\n  nickname: {type: string}\n",
+	}
+	tests := map[string]struct {
+		opts Options
+		want []string
+	}{
+		"context": {want: []string{"Pet", "V2Pet"}},
+		"numbers": {opts: Options{CollisionResolver: NumberResolver{}}, want: []string{"Pet", "Pet2"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			set, err := LoadFiles("openapi.yaml", parseFiles(files), tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, sortedNames(set.added))
+		})
+	}
+}
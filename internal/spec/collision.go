@@ -0,0 +1,97 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// CollisionResolver chooses the name of an extracted schema when the name
+// it would be given already exists in components.schemas.
+type CollisionResolver interface {
+	// Resolve returns a name, for which exists returns false, to use instead
+	// of name. data describes where the schema was found.
+	Resolve(name string, data NameData, exists func(name string) bool) (string, error)
+}
+
+// ContextResolver qualifies a name with the context in which the schema was
+// found: its parent schema, verb, path segments (last first) and then status
+// code, skipping any already in the name. If every qualified name is taken it
+// falls back to NumberResolver.
+type ContextResolver struct{}
+
+func (ContextResolver) Resolve(name string, data NameData, exists func(name string) bool) (string, error) {
+	var candidates []string
+	for _, prefix := range append([]string{data.Parent, data.Verb}, reversed(data.PathSegments)...) {
+		if prefix != "" && !strings.Contains(name, prefix) {
+			candidates = append(candidates, prefix+name)
+		}
+	}
+	if statusCode := sanitizeURLPath(data.StatusCode); statusCode != "" && !strings.Contains(name, statusCode) {
+		candidates = append(candidates, name+statusCode)
+	}
+	for _, candidate := range candidates {
+		if !exists(candidate) {
+			return candidate, nil
+		}
+	}
+	return NumberResolver{}.Resolve(name, data, exists)
+}
+
+// NumberResolver appends the lowest number from 2 which gives a new name. If
+// the name already ends in a digit, the number is separated by an underscore
+// so that the existing digits are unchanged, e.g. PostV2 becomes PostV2_2.
+type NumberResolver struct{}
+
+// maxNumber bounds the search for an unused number
+const maxNumber = 10000
+
+func (NumberResolver) Resolve(name string, _ NameData, exists func(name string) bool) (string, error) {
+	separator := ""
+	if r := []rune(name); len(r) > 0 && unicode.IsDigit(r[len(r)-1]) {
+		separator = "_"
+	}
+	for i := 2; i < maxNumber; i++ {
+		candidate := fmt.Sprintf("%s%s%d", name, separator, i)
+		if !exists(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no unused name found for %s", name)
+}
+
+// resolveCollision returns symbol, or if it exists the name given by resolver,
+// or ContextResolver if nil, made a valid identifier. data describes where the
+// schema was found.
+func resolveCollision(resolver CollisionResolver, symbol string, data func() (NameData, error), exists func(name string) bool) (string, error) {
+	if !exists(symbol) {
+		return symbol, nil
+	}
+	d, err := data()
+	if err != nil {
+		return "", err
+	}
+	if resolver == nil {
+		resolver = ContextResolver{}
+	}
+	resolved, err := resolver.Resolve(symbol, d, exists)
+	if err != nil {
+		return "", err
+	}
+	ret := toIdentifier(resolved)
+	if ret == "" {
+		return "", fmt.Errorf("collision resolver gave invalid name %q for %s", resolved, symbol)
+	}
+	if exists(ret) {
+		return "", fmt.Errorf("collision resolver gave existing name %q for %s", resolved, symbol)
+	}
+	return ret, nil
+}
+
+func reversed(values []string) []string {
+	ret := make([]string, len(values))
+	for i, v := range values {
+		ret[len(values)-1-i] = v
+	}
+	return ret
+}
@@ -0,0 +1,205 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func existing(names ...string) func(string) bool {
+	return func(name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestNumberResolver_Resolve(t *testing.T) {
+	tests := map[string]struct {
+		in       string
+		existing []string
+		want     string
+	}{
+		"no suffix": {
+			in:   "Foo",
+			want: "Foo2",
+		},
+		"next unused": {
+			in:       "Foo",
+			existing: []string{"Foo2", "Foo3"},
+			want:     "Foo4",
+		},
+		"ends in digit": {
+			in:   "PostV2",
+			want: "PostV2_2",
+		},
+		"suffix another number": {
+			in:       "Foo999",
+			existing: []string{"Foo999_2"},
+			want:     "Foo999_3",
+		},
+		"all digits": {
+			in:   "200",
+			want: "200_2",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := NumberResolver{}.Resolve(tt.in, NameData{}, existing(tt.existing...))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestContextResolver_Resolve(t *testing.T) {
+	tests := map[string]struct {
+		in       string
+		data     NameData
+		existing []string
+		want     string
+	}{
+		"parent": {
+			in:   "Address",
+			data: NameData{Parent: "Pet", Property: "Address"},
+			want: "PetAddress",
+		},
+		"verb": {
+			in:   "Pet",
+			data: NameData{Verb: "Post", Path: "V2Pets", PathSegments: []string{"V2", "Pets"}},
+			want: "PostPet",
+		},
+		"path segment, last first": {
+			in:       "Pet",
+			data:     NameData{Verb: "Post", Path: "V2Owners", PathSegments: []string{"V2", "Owners"}},
+			existing: []string{"PostPet"},
+			want:     "OwnersPet",
+		},
+		"status code": {
+			in:   "PostPetsResponse",
+			data: NameData{Verb: "Post", Path: "Pets", PathSegments: []string{"Pets"}, StatusCode: "201"},
+			want: "PostPetsResponse201",
+		},
+		"already qualified": {
+			in:   "PostV2FooRequest",
+			data: NameData{Verb: "Post", Path: "V2Foo", PathSegments: []string{"V2", "Foo"}},
+			want: "PostV2FooRequest2",
+		},
+		"never alters digits": {
+			in:       "PostV2",
+			data:     NameData{Verb: "Post", PathSegments: []string{"V2"}},
+			existing: []string{"PostV2"},
+			want:     "PostV2_2",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ContextResolver{}.Resolve(tt.in, tt.data, existing(append(tt.existing, tt.in)...))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+type fixedResolver string
+
+func (r fixedResolver) Resolve(string, NameData, func(string) bool) (string, error) {
+	return string(r), nil
+}
+
+func TestSpec_Transform_collisionResolver(t *testing.T) {
+	in := `
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                address:
+                  type: object
+                  properties:
+                    street: {type: string}
+components:
+  schemas:
+    PostPetsRequest:
+      type: string
+    PostPetsRequestAddress:
+      type: string
+`
+	tests := map[string]struct {
+		resolver CollisionResolver
+		want     []string
+		wantErr  string
+	}{
+		"default": {
+			want: []string{"PostPetsRequest2", "PostPetsRequest2Address"},
+		},
+		"numbers": {
+			resolver: NumberResolver{},
+			want:     []string{"PostPetsRequest2", "PostPetsRequest2Address"},
+		},
+		"custom": {
+			resolver: fixedResolver("Custom"),
+			want:     []string{"Custom", "CustomAddress"},
+		},
+		"made an identifier": {
+			resolver: fixedResolver("my-custom"),
+			want:     []string{"myCustom", "myCustomAddress"},
+		},
+		"invalid name": {
+			resolver: fixedResolver("--"),
+			wantErr:  `collision resolver gave invalid name "--" for PostPetsRequest`,
+		},
+		"existing name": {
+			resolver: fixedResolver("PostPetsRequest"),
+			wantErr:  `collision resolver gave existing name "PostPetsRequest" for PostPetsRequest`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(in))
+			assert.NoError(t, err)
+			_, extractions, err := s.Transform(Options{CollisionResolver: tt.resolver})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			var got []string
+			for _, e := range extractions {
+				got = append(got, e.Schema)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSpec_Transform_collisionHyphenatedParent(t *testing.T) {
+	in := `
+components:
+  schemas:
+    pet-owner:
+      type: object
+      properties:
+        address:
+          type: object
+          properties:
+            street: {type: string}
+    petOwnerAddress:
+      type: string
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	_, extractions, err := s.Transform(Options{})
+	assert.NoError(t, err)
+	if assert.Len(t, extractions, 1) {
+		assert.Equal(t, "petOwnerAddress2", extractions[0].Schema)
+	}
+}
@@ -0,0 +1,271 @@
+package spec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// defaultUnorderedKeys are the keywords whose values are sets, so that the
+// order of their items does not matter
+var defaultUnorderedKeys = []string{"required", "enum"}
+
+// Comparator decides whether two schemas are the same, and so are extracted
+// as one. The zero value compares schemas exactly, except that the order of
+// required and enum is ignored.
+type Comparator struct {
+	// IgnoreKeys are keywords ignored wherever they appear in a schema, e.g.
+	// description, example. Where schemas differing only in these are
+	// grouped, the first is kept.
+	IgnoreKeys []string
+	// UnorderedKeys are keywords whose values are compared without regard to
+	// order. If nil, required and enum are used; if empty, order always
+	// matters.
+	UnorderedKeys []string
+	// ResolveRefs compares a reference to components.schemas as the schema
+	// it refers to, so that an inline schema matches a reference to an
+	// identical schema.
+	ResolveRefs bool
+}
+
+// schemaMapKeys are keywords whose value maps names to schemas
+var schemaMapKeys = map[string]bool{
+	"properties":        true,
+	"patternProperties": true,
+	"dependentSchemas":  true,
+	"definitions":       true,
+	"$defs":             true,
+}
+
+// schemaKeys are keywords whose value is a schema
+var schemaKeys = map[string]bool{
+	"items":                 true,
+	"additionalProperties":  true,
+	"additionalItems":       true,
+	"unevaluatedItems":      true,
+	"unevaluatedProperties": true,
+	"not":                   true,
+	"contains":              true,
+	"propertyNames":         true,
+	"if":                    true,
+	"then":                  true,
+	"else":                  true,
+}
+
+// schemaListKeys are keywords whose value is a list of schemas
+var schemaListKeys = map[string]bool{
+	"allOf":       true,
+	"anyOf":       true,
+	"oneOf":       true,
+	"prefixItems": true,
+}
+
+// canonicalizer hashes the canonical form of schemas, so that schemas which
+// the Comparator considers the same have the same hash. The canonical form of
+// a schema includes the hashes of its subschemas, which are cached so that
+// hashing every schema in a document takes linear time.
+type canonicalizer struct {
+	ignore    map[string]bool
+	unordered map[string]bool
+	// schemas is components.schemas, if references are resolved
+	schemas object
+	// cache holds the hash of each schema by identity. The schema is held so
+	// that its address cannot be reused while it is cached.
+	cache map[uintptr]cachedHash
+	// byDepth hashes a reference to a schema being hashed by how many
+	// schemas up it is, rather than by name
+	byDepth bool
+}
+
+type cachedHash struct {
+	schema object
+	hash   string
+}
+
+func (c Comparator) canonicalizer(schemas object) *canonicalizer {
+	unordered := c.UnorderedKeys
+	if unordered == nil {
+		unordered = defaultUnorderedKeys
+	}
+	ret := &canonicalizer{ignore: toSet(c.IgnoreKeys), unordered: toSet(unordered)}
+	if c.ResolveRefs {
+		ret.schemas = schemas
+	} else {
+		// The hash of a schema can depend on the references being resolved
+		// when it is reached, so is only cached if none are
+		ret.cache = map[uintptr]cachedHash{}
+	}
+	return ret
+}
+
+func toSet(values []string) map[string]bool {
+	ret := make(map[string]bool, len(values))
+	for _, v := range values {
+		ret[v] = true
+	}
+	return ret
+}
+
+// equal reports whether two schemas are the same
+func (c *canonicalizer) equal(a, b object) bool {
+	return c.canonical(a) == c.canonical(b)
+}
+
+// canonical returns the hash of the canonical form of a schema
+func (c *canonicalizer) canonical(schema object) string {
+	return c.hash(schema, nil)
+}
+
+// matchRecursive makes references back to the schema being indexed, or to any
+// schema being resolved, hash by how many schemas up they refer to, so that
+// identical recursive schemas with different names match, e.g. LongName
+// {next: LongName} and Ab {next: Ab}. Hashes then depend on where a schema is
+// reached from, so are not cached.
+func (c *canonicalizer) matchRecursive() *canonicalizer {
+	c.byDepth = true
+	c.cache = nil
+	return c
+}
+
+// canonicalNamed returns the hash of the canonical form of the named schema
+func (c *canonicalizer) canonicalNamed(name string, schema object) string {
+	if !c.byDepth {
+		return c.canonical(schema)
+	}
+	return c.hash(schema, []string{name})
+}
+
+// invalidate removes schemas which have changed from the cache. Any schema
+// containing them must also be invalidated.
+func (c *canonicalizer) invalidate(schemas ...object) {
+	for _, schema := range schemas {
+		delete(c.cache, reflect.ValueOf(schema).Pointer())
+	}
+}
+
+// hash returns the hash of the canonical form of a schema. resolving holds
+// the names of the schemas being resolved, outermost first, so that recursive
+// schemas terminate.
+func (c *canonicalizer) hash(v interface{}, resolving []string) string {
+	schema, ok := v.(object)
+	if !ok {
+		var sb strings.Builder
+		writeValue(&sb, v)
+		return sb.String()
+	}
+	if ref, ok := schema["$ref"].(string); ok && len(schema) == 1 {
+		if name := strings.TrimPrefix(ref, "#/components/schemas/"); name != ref {
+			depth := 0
+			for i := len(resolving) - 1; i >= 0 && depth == 0; i-- {
+				if resolving[i] == name {
+					depth = len(resolving) - i
+				}
+			}
+			if depth > 0 && c.byDepth {
+				return fmt.Sprintf("self:%d", depth)
+			}
+			if target, ok := c.schemas[name].(object); ok && depth == 0 {
+				return c.hash(target, append(resolving[:len(resolving):len(resolving)], name))
+			}
+		}
+	}
+	key := reflect.ValueOf(schema).Pointer()
+	if cached, ok := c.cache[key]; ok {
+		return cached.hash
+	}
+	var sb strings.Builder
+	c.writeSchema(&sb, schema, resolving)
+	sum := sha256.Sum256([]byte(sb.String()))
+	ret := hex.EncodeToString(sum[:])
+	if c.cache != nil {
+		c.cache[key] = cachedHash{schema: schema, hash: ret}
+	}
+	return ret
+}
+
+// writeSchema writes the canonical form of a schema, in which subschemas are
+// given by their hash.
+func (c *canonicalizer) writeSchema(sb *strings.Builder, schema object, resolving []string) {
+	sb.WriteString("{")
+	for _, k := range schema.sortedKeys() {
+		key := fmt.Sprintf("%v", k)
+		if c.ignore[key] {
+			continue
+		}
+		writeValue(sb, k)
+		sb.WriteString(":")
+		switch val := schema[k]; {
+		case schemaMapKeys[key]:
+			if m, ok := val.(object); ok {
+				sb.WriteString("{")
+				for _, name := range m.sortedKeys() {
+					writeValue(sb, name)
+					sb.WriteString(":")
+					sb.WriteString(c.hash(m[name], resolving))
+					sb.WriteString(",")
+				}
+				sb.WriteString("}")
+			} else {
+				writeValue(sb, val)
+			}
+		case schemaKeys[key]:
+			sb.WriteString(c.hash(val, resolving))
+		case schemaListKeys[key]:
+			if list, ok := val.([]interface{}); ok {
+				sb.WriteString("[")
+				for _, item := range list {
+					sb.WriteString(c.hash(item, resolving))
+					sb.WriteString(",")
+				}
+				sb.WriteString("]")
+			} else {
+				writeValue(sb, val)
+			}
+		case c.unordered[key]:
+			if list, ok := val.([]interface{}); ok {
+				items := make([]string, len(list))
+				for i, item := range list {
+					var itemSb strings.Builder
+					writeValue(&itemSb, item)
+					items[i] = itemSb.String()
+				}
+				sort.Strings(items)
+				sb.WriteString("[" + strings.Join(items, ",") + "]")
+			} else {
+				writeValue(sb, val)
+			}
+		default:
+			writeValue(sb, val)
+		}
+		sb.WriteString(",")
+	}
+	sb.WriteString("}")
+}
+
+// writeValue writes any value unambiguously, including its type, so that
+// e.g. 1 and "1" differ.
+func writeValue(sb *strings.Builder, v interface{}) {
+	switch val := v.(type) {
+	case object:
+		sb.WriteString("{")
+		for _, k := range val.sortedKeys() {
+			writeValue(sb, k)
+			sb.WriteString(":")
+			writeValue(sb, val[k])
+			sb.WriteString(",")
+		}
+		sb.WriteString("}")
+	case []interface{}:
+		sb.WriteString("[")
+		for _, item := range val {
+			writeValue(sb, item)
+			sb.WriteString(",")
+		}
+		sb.WriteString("]")
+	default:
+		fmt.Fprintf(sb, "%T(%q)", val, fmt.Sprintf("%v", val))
+	}
+}
@@ -0,0 +1,178 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComparator_equal(t *testing.T) {
+	schemas := object{
+		"Address": object{"type": "object", "properties": object{"street": object{"type": "string"}}},
+		"Node": object{"type": "object", "properties": object{
+			"next": object{"$ref": "#/components/schemas/Node"},
+		}},
+	}
+	ignoreDocs := Comparator{IgnoreKeys: []string{"description", "example"}}
+	tests := map[string]struct {
+		comparator Comparator
+		a, b       object
+		want       bool
+	}{
+		"identical": {
+			a:    object{"type": "string"},
+			b:    object{"type": "string"},
+			want: true,
+		},
+		"description differs": {
+			a:    object{"type": "string", "description": "a"},
+			b:    object{"type": "string", "description": "b"},
+			want: false,
+		},
+		"description ignored": {
+			comparator: ignoreDocs,
+			a:          object{"type": "object", "description": "a", "properties": object{"id": object{"type": "string", "example": "x"}}},
+			b:          object{"type": "object", "properties": object{"id": object{"type": "string", "example": "y"}}},
+			want:       true,
+		},
+		"property named as ignored keyword": {
+			comparator: ignoreDocs,
+			a:          object{"type": "object", "properties": object{"description": object{"type": "string"}}},
+			b:          object{"type": "object", "properties": object{}},
+			want:       false,
+		},
+		"ignored keyword within example data": {
+			comparator: Comparator{IgnoreKeys: []string{"description"}},
+			a:          object{"type": "object", "default": object{"description": "a"}},
+			b:          object{"type": "object", "default": object{"description": "b"}},
+			want:       false,
+		},
+		"required order": {
+			a:    object{"type": "object", "required": []interface{}{"a", "b"}},
+			b:    object{"type": "object", "required": []interface{}{"b", "a"}},
+			want: true,
+		},
+		"enum order": {
+			a:    object{"type": "string", "enum": []interface{}{"a", "b"}},
+			b:    object{"type": "string", "enum": []interface{}{"b", "a"}},
+			want: true,
+		},
+		"enum order matters": {
+			comparator: Comparator{UnorderedKeys: []string{}},
+			a:          object{"type": "string", "enum": []interface{}{"a", "b"}},
+			b:          object{"type": "string", "enum": []interface{}{"b", "a"}},
+			want:       false,
+		},
+		"composition order matters": {
+			a:    object{"oneOf": []interface{}{object{"type": "string"}, object{"type": "integer"}}},
+			b:    object{"oneOf": []interface{}{object{"type": "integer"}, object{"type": "string"}}},
+			want: false,
+		},
+		"types differ": {
+			a:    object{"type": "string", "enum": []interface{}{1}},
+			b:    object{"type": "string", "enum": []interface{}{"1"}},
+			want: false,
+		},
+		"ref not resolved": {
+			a:    object{"type": "object", "properties": object{"address": object{"$ref": "#/components/schemas/Address"}}},
+			b:    object{"type": "object", "properties": object{"address": schemas["Address"]}},
+			want: false,
+		},
+		"ref resolved": {
+			comparator: Comparator{ResolveRefs: true},
+			a:          object{"type": "object", "properties": object{"address": object{"$ref": "#/components/schemas/Address"}}},
+			b:          object{"type": "object", "properties": object{"address": schemas["Address"]}},
+			want:       true,
+		},
+		"recursive ref resolved": {
+			comparator: Comparator{ResolveRefs: true},
+			a:          object{"$ref": "#/components/schemas/Node"},
+			b:          object{"$ref": "#/components/schemas/Node"},
+			want:       true,
+		},
+		// A recursive schema is only resolved once, so differs from a copy
+		// which has been expanded one more level
+		"recursive ref expanded": {
+			comparator: Comparator{ResolveRefs: true},
+			a:          object{"$ref": "#/components/schemas/Node"},
+			b:          schemas["Node"].(object),
+			want:       false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.comparator.canonicalizer(schemas).equal(tt.a, tt.b))
+		})
+	}
+}
+
+func TestSpec_Transform_comparator(t *testing.T) {
+	in := `
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              description: A new pet
+              required: [name, age]
+              properties:
+                name: {type: string}
+                age: {type: integer}
+                address:
+                  type: object
+                  properties:
+                    street: {type: string}
+    put:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              description: An updated pet
+              required: [age, name]
+              properties:
+                name: {type: string}
+                age: {type: integer}
+                address:
+                  $ref: '#/components/schemas/Address'
+components:
+  schemas:
+    Address:
+      type: object
+      properties:
+        street: {type: string}
+`
+	tests := map[string]struct {
+		comparator Comparator
+		want       []string
+	}{
+		"default": {
+			want: []string{"PutPetsRequest", "PostPetsRequest", "Address"},
+		},
+		"ignore description": {
+			comparator: Comparator{IgnoreKeys: []string{"description"}},
+			want:       []string{"PutPetsRequest", "PostPetsRequest", "Address"},
+		},
+		"ignore description and resolve refs": {
+			comparator: Comparator{IgnoreKeys: []string{"description"}, ResolveRefs: true},
+			want:       []string{"CommonPetsRequest"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(in))
+			assert.NoError(t, err)
+			_, extractions, err := s.Transform(Options{Comparator: tt.comparator})
+			assert.NoError(t, err)
+			var got []string
+			for _, e := range extractions {
+				got = append(got, e.Schema)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// CanonicalRule chooses which of a group of identical schemas is kept by
+// Dedupe
+type CanonicalRule string
+
+const (
+	// CanonicalShortest keeps the schema with the shortest name
+	CanonicalShortest CanonicalRule = "shortest"
+	// CanonicalFirst keeps the schema whose name sorts first
+	CanonicalFirst CanonicalRule = "first"
+	// CanonicalMostReferenced keeps the schema with the most references,
+	// falling back to the shortest name
+	CanonicalMostReferenced CanonicalRule = "mostReferenced"
+)
+
+// DedupeOptions controls the behaviour of Dedupe. The zero value keeps the
+// schema with the shortest name and logs nothing.
+type DedupeOptions struct {
+	// Comparator decides whether schemas are the same
+	Comparator Comparator
+	// Canonical chooses which of a group of identical schemas is kept.
+	// CanonicalShortest is used if empty.
+	Canonical CanonicalRule
+	// Log receives progress messages, if set.
+	Log io.Writer
+}
+
+// Duplicate describes a schema which has been replaced by an identical schema
+type Duplicate struct {
+	// Schema is the name of the schema removed from components.schemas
+	Schema string `json:"schema"`
+	// ReplacedBy is the name of the schema which its references now refer to
+	ReplacedBy string `json:"replacedBy"`
+}
+
+// Dedupe replaces the schemas of components.schemas which opts.Comparator
+// considers the same as another with references to a single schema of each
+// group, chosen by opts.Canonical, and removes them. A reference back to the
+// schema itself is compared by position rather than by name, so that identical
+// recursive schemas are combined; mutually recursive schemas are only combined
+// if references are resolved.
+func (s Spec) Dedupe(opts DedupeOptions) (Spec, []Duplicate, error) {
+	log := opts.Log
+	if log == nil {
+		log = io.Discard
+	}
+	var choose func(names []string, refs map[string]int) string
+	switch opts.Canonical {
+	case CanonicalShortest, "":
+		choose = func(names []string, refs map[string]int) string {
+			return shortestName(names)
+		}
+	case CanonicalFirst:
+		choose = func(names []string, refs map[string]int) string {
+			return names[0]
+		}
+	case CanonicalMostReferenced:
+		choose = func(names []string, refs map[string]int) string {
+			most := 0
+			var candidates []string
+			for _, name := range names {
+				switch {
+				case refs[name] > most:
+					most, candidates = refs[name], []string{name}
+				case refs[name] == most:
+					candidates = append(candidates, name)
+				}
+			}
+			return shortestName(candidates)
+		}
+	default:
+		return s, nil, fmt.Errorf("unknown canonical rule %q", opts.Canonical)
+	}
+
+	ret := s.copy()
+	replaced := ret.replaceDuplicates(opts.Comparator, choose, func(string) bool { return true })
+	ret.removeSchemasIfEmpty()
+	duplicates := make([]Duplicate, 0, len(replaced))
+	for name, to := range replaced {
+		duplicates = append(duplicates, Duplicate{Schema: name, ReplacedBy: to})
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].Schema < duplicates[j].Schema
+	})
+	for _, d := range duplicates {
+		fmt.Fprintf(log, "Replaced %s with %s\n", d.Schema, d.ReplacedBy)
+	}
+	return ret, duplicates, nil
+}
+
+// shortestName returns the shortest of names, which are sorted, or the first
+// of those with the same length
+func shortestName(names []string) string {
+	ret := names[0]
+	for _, name := range names[1:] {
+		if len(name) < len(ret) {
+			ret = name
+		}
+	}
+	return ret
+}
+
+// replaceDuplicates replaces the schemas of components.schemas which c
+// considers the same as another with references to the one chosen from each
+// group by choose, given the names in sorted order and the number of
+// references to each schema. Only schemas for which replaceable returns true
+// are replaced. It returns the name of the schema which replaced each.
+func (s Spec) replaceDuplicates(c Comparator, choose func(names []string, refs map[string]int) string, replaceable func(name string) bool) map[string]string {
+	found, _ := lookupPath(s.object, _path{"components", "schemas"})
+	schemas, _ := found.(object)
+	replaced := map[string]string{}
+	// Replacing a schema can make those referring to it the same as others,
+	// so repeat until nothing is replaced
+	for {
+		refs := map[string]int{}
+		for _, r := range findSchemaRefs(s.object, nil) {
+			refs[r.name]++
+		}
+		// A schema which is only a reference is an alias rather than a
+		// duplicate, and would refer to itself if it replaced its target
+		candidates := object{}
+		for k, v := range schemas {
+			if schema, ok := v.(object); ok && !(len(schema) == 1 && schema["$ref"] != nil) {
+				candidates[k] = schema
+			}
+		}
+		index := newSchemaIndex(c.canonicalizer(schemas).matchRecursive(), candidates)
+		renames := map[string]string{}
+		for _, group := range index.names {
+			if len(group) < 2 {
+				continue
+			}
+			names := sortedNames(group)
+			canonical := choose(names, refs)
+			for _, name := range names {
+				if name != canonical && replaceable(name) {
+					renames[name] = canonical
+				}
+			}
+		}
+		if len(renames) == 0 {
+			break
+		}
+		for name, to := range renames {
+			delete(schemas, name)
+			replaced[name] = to
+		}
+		renameRefs(s.object, renames)
+	}
+	// Follow schemas replaced by schemas replaced later
+	for name, to := range replaced {
+		for {
+			next, ok := replaced[to]
+			if !ok {
+				break
+			}
+			to = next
+		}
+		replaced[name] = to
+	}
+	return replaced
+}
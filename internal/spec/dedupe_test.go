@@ -0,0 +1,199 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpec_Dedupe(t *testing.T) {
+	input := `
+paths:
+  /pets:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/PetOwner'
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/PetModel'
+      responses:
+        201:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/PetModel'
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name: {type: string}
+    PetModel:
+      type: object
+      properties:
+        name: {type: string}
+    Owner:
+      type: object
+      properties:
+        pet:
+          $ref: '#/components/schemas/Pet'
+    PetOwner:
+      type: object
+      properties:
+        pet:
+          $ref: '#/components/schemas/PetModel'
+    Name:
+      $ref: '#/components/schemas/PetModel/properties/name'
+    Animal:
+      oneOf:
+        - $ref: '#/components/schemas/PetModel'
+      discriminator:
+        propertyName: kind
+        mapping:
+          pet: '#/components/schemas/PetModel'
+`
+	tests := map[string]struct {
+		opts    DedupeOptions
+		want    []Duplicate
+		refs    map[string]interface{}
+		wantErr string
+	}{
+		"shortest": {
+			want: []Duplicate{{Schema: "PetModel", ReplacedBy: "Pet"}, {Schema: "PetOwner", ReplacedBy: "Owner"}},
+			refs: map[string]interface{}{
+				"/paths/~1pets/get/responses/200/content/application~1json/schema": object{"$ref": "#/components/schemas/Owner"},
+				"/paths/~1pets/post/requestBody/content/application~1json/schema":  object{"$ref": "#/components/schemas/Pet"},
+				"/components/schemas/Name":                             object{"$ref": "#/components/schemas/Pet/properties/name"},
+				"/components/schemas/Animal/discriminator/mapping/pet": "#/components/schemas/Pet",
+			},
+		},
+		"first": {
+			opts: DedupeOptions{Canonical: CanonicalFirst},
+			want: []Duplicate{{Schema: "PetModel", ReplacedBy: "Pet"}, {Schema: "PetOwner", ReplacedBy: "Owner"}},
+		},
+		"most referenced": {
+			opts: DedupeOptions{Canonical: CanonicalMostReferenced},
+			want: []Duplicate{{Schema: "Owner", ReplacedBy: "PetOwner"}, {Schema: "Pet", ReplacedBy: "PetModel"}},
+			refs: map[string]interface{}{
+				"/components/schemas/PetOwner/properties/pet": object{"$ref": "#/components/schemas/PetModel"},
+			},
+		},
+		"unknown rule": {
+			opts:    DedupeOptions{Canonical: "longest"},
+			wantErr: `unknown canonical rule "longest"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(input))
+			assert.NoError(t, err)
+			out, duplicates, err := s.Dedupe(tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, duplicates)
+			schemas, err := out.schemasNode()
+			assert.NoError(t, err)
+			for _, d := range tt.want {
+				assert.NotContains(t, schemas, d.Schema)
+			}
+			for pointer, want := range tt.refs {
+				got, ok := lookupPointer(out.object, pointer)
+				assert.True(t, ok, pointer)
+				assert.Equal(t, want, got, pointer)
+			}
+		})
+	}
+}
+
+func TestSpec_Dedupe_aliases(t *testing.T) {
+	input := `
+components:
+  schemas:
+    P:
+      $ref: '#/components/schemas/Pet'
+    Pet:
+      type: object
+`
+	s, err := NewFromYaml(strings.NewReader(input))
+	assert.NoError(t, err)
+	out, duplicates, err := s.Dedupe(DedupeOptions{Comparator: Comparator{ResolveRefs: true}})
+	assert.NoError(t, err)
+	assert.Empty(t, duplicates)
+	assert.Equal(t, s.object, out.object)
+}
+
+func TestSpec_Dedupe_recursive(t *testing.T) {
+	input := `
+components:
+  schemas:
+    LongName:
+      type: object
+      properties:
+        next:
+          $ref: '#/components/schemas/LongName'
+    Ab:
+      type: object
+      properties:
+        next:
+          $ref: '#/components/schemas/Ab'
+    Tree:
+      type: object
+      properties:
+        forest:
+          $ref: '#/components/schemas/Forest'
+    Forest:
+      type: array
+      items:
+        $ref: '#/components/schemas/Tree'
+    Node:
+      type: object
+      properties:
+        forest:
+          $ref: '#/components/schemas/Nodes'
+    Nodes:
+      type: array
+      items:
+        $ref: '#/components/schemas/Node'
+`
+	tests := map[string]struct {
+		opts DedupeOptions
+		want []Duplicate
+	}{
+		// Only references back to the schema itself are matched by position
+		// unless references are resolved
+		"self references": {
+			want: []Duplicate{{Schema: "LongName", ReplacedBy: "Ab"}},
+		},
+		"resolved references": {
+			opts: DedupeOptions{Comparator: Comparator{ResolveRefs: true}},
+			want: []Duplicate{
+				{Schema: "Forest", ReplacedBy: "Nodes"},
+				{Schema: "LongName", ReplacedBy: "Ab"},
+				{Schema: "Tree", ReplacedBy: "Node"},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(input))
+			assert.NoError(t, err)
+			out, duplicates, err := s.Dedupe(tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, duplicates)
+			next, ok := lookupPointer(out.object, "/components/schemas/Ab/properties/next")
+			assert.True(t, ok)
+			assert.Equal(t, object{"$ref": "#/components/schemas/Ab"}, next)
+		})
+	}
+}
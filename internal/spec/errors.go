@@ -0,0 +1,26 @@
+package spec
+
+import "fmt"
+
+// TransformError reports a part of the document which could not be
+// transformed.
+type TransformError struct {
+	// Pointer is the JSON pointer to the part of the document
+	Pointer string
+	// Reason describes why it could not be transformed
+	Reason string
+	// Err is the underlying error, if any
+	Err error
+}
+
+func (e *TransformError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Pointer, e.Reason)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,650 @@
+package spec
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileSet is a document split across files joined by relative references.
+// It is loaded as a single merged document, in which schemas from other files
+// are added to components.schemas and other referenced content is inline, and
+// the merged document is split back into its files after transforming it.
+type FileSet struct {
+	root     string
+	parse    func(name string) (*Spec, error)
+	resolver CollisionResolver
+	files    map[string]*Spec
+	// Merged is the merged document
+	Merged Spec
+	// names are the names in components.schemas of the schemas in other files
+	names map[fileRef]string
+	// external are the locations of the schemas named in names
+	external map[string]fileRef
+	// added are the external schemas which were not already in
+	// components.schemas of the root document, so are removed on splitting
+	added map[string]bool
+	// inlined are the references replaced by their content, outermost first
+	inlined []inlined
+	// refs are the original references in each file, by the reference which
+	// replaced them in the merged document
+	refs map[string]map[string]string
+}
+
+// fileRef is the location of content within a file
+type fileRef struct {
+	file, pointer string
+}
+
+// inlined is a reference replaced by its content in the merged document
+type inlined struct {
+	path   _path
+	target fileRef
+	ref    object
+}
+
+// LoadFiles loads the document in the named file, and every file it refers to
+// with a relative reference. parse reads and parses a file. Schemas from other
+// files whose names are taken are renamed by opts.CollisionResolver.
+func LoadFiles(name string, parse func(name string) (*Spec, error), opts Options) (*FileSet, error) {
+	f := &FileSet{
+		root:     path.Clean(filepath.ToSlash(name)),
+		parse:    parse,
+		resolver: opts.CollisionResolver,
+		files:    map[string]*Spec{},
+		names:    map[fileRef]string{},
+		external: map[string]fileRef{},
+		added:    map[string]bool{},
+		refs:     map[string]map[string]string{},
+	}
+	root, err := f.file(f.root)
+	if err != nil {
+		return nil, err
+	}
+	f.Merged = Spec{object: copyObject(root.object), node: root.node, indent: root.indent, json: root.json, text: root.text}
+
+	// Schemas of the root document which refer to other files keep their names
+	for _, found := range f.Merged.findStringPath("$.components.schemas.*") {
+		ref, ok := found.object["$ref"].(string)
+		if !ok {
+			continue
+		}
+		target, ok := f.target(f.root, ref)
+		if !ok || target.file == f.root {
+			continue
+		}
+		name := found.path[2]
+		if _, ok := f.names[target]; !ok {
+			f.names[target] = name
+			f.external[name] = target
+		}
+	}
+
+	if _, err := f.resolve(f.Merged.object, nil, f.root, false, nil); err != nil {
+		return nil, err
+	}
+	f.graftNodes()
+	return f, nil
+}
+
+// graftNodes gives the merged document the nodes of the content of other
+// files, so that it keeps their formatting.
+func (f *FileSet) graftNodes() {
+	if f.Merged.node == nil {
+		return
+	}
+	copies := map[*yaml.Node]*yaml.Node{}
+	f.Merged.node = copyNode(f.Merged.node, copies)
+	for _, in := range f.inlined {
+		if n := f.node(in.target); n != nil {
+			graftNode(f.Merged.node, in.path, copyNode(n, copies))
+		}
+	}
+	if len(f.added) == 0 {
+		return
+	}
+	schemas := childMapping(childMapping(f.Merged.node.Content[0], "components"), "schemas")
+	if schemas == nil {
+		return
+	}
+	for _, name := range sortedNames(f.added) {
+		if n := f.node(f.external[name]); n != nil {
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+			schemas.Content = append(schemas.Content, key, copyNode(n, copies))
+		}
+	}
+}
+
+// childMapping returns the mapping under key in the mapping n, adding it if
+// there is none, or nil if n is not a mapping or key holds something else.
+func childMapping(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	if child := lookupNode(n, _path{key}); child != nil {
+		if child.Kind != yaml.MappingNode {
+			return nil
+		}
+		return child
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+	return child
+}
+
+// node returns the original node of the content at target. Content of a JSON
+// file grafted into a yaml document loses its flow style.
+func (f *FileSet) node(target fileRef) *yaml.Node {
+	file := f.files[target.file]
+	n := lookupNode(file.node, pointerPath(target.pointer))
+	if n != nil && n.Kind == yaml.DocumentNode {
+		if len(n.Content) == 0 {
+			return nil
+		}
+		n = n.Content[0]
+	}
+	if n != nil && file.json && !f.Merged.json {
+		return blockStyle(copyNode(n, map[*yaml.Node]*yaml.Node{}))
+	}
+	return n
+}
+
+// Files returns the names of the files loaded, the root document first
+func (f *FileSet) Files() []string {
+	ret := []string{f.root}
+	for name := range f.files {
+		if name != f.root {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret[1:])
+	return ret
+}
+
+func (f *FileSet) file(name string) (*Spec, error) {
+	if s, ok := f.files[name]; ok {
+		return s, nil
+	}
+	s, err := f.parse(name)
+	if err != nil {
+		return nil, err
+	}
+	f.files[name] = s
+	return s, nil
+}
+
+// target returns the location referred to by ref within file. It returns
+// false for references which are not relative, e.g. to a URL.
+func (f *FileSet) target(file, ref string) (fileRef, bool) {
+	if strings.Contains(ref, "://") {
+		return fileRef{}, false
+	}
+	name, pointer, _ := strings.Cut(ref, "#")
+	if name == "" {
+		return fileRef{file: file, pointer: pointer}, true
+	}
+	return fileRef{file: path.Join(path.Dir(file), name), pointer: pointer}, true
+}
+
+// content returns a copy of the content at target
+func (f *FileSet) content(target fileRef) (interface{}, error) {
+	s, err := f.file(target.file)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := lookupPointer(s.object, target.pointer)
+	if !ok {
+		return nil, fmt.Errorf("%s: no content at #%s", target.file, target.pointer)
+	}
+	return copyValue(v), nil
+}
+
+// resolve replaces the references within v, from file, at path in the merged
+// document, returning the new value. inSchema is set within schemas. stack
+// holds the references being inlined, to detect cycles.
+func (f *FileSet) resolve(v interface{}, p _path, file string, inSchema bool, stack []fileRef) (interface{}, error) {
+	switch val := v.(type) {
+	case []interface{}:
+		for i := range val {
+			resolved, err := f.resolve(val[i], p.child(strconv.Itoa(i)), file, inSchema, stack)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = resolved
+		}
+	case object:
+		if ref, ok := val["$ref"].(string); ok {
+			return f.resolveRef(val, ref, p, file, inSchema, stack)
+		}
+		if inSchema {
+			if err := f.resolveMapping(val, file); err != nil {
+				return nil, err
+			}
+		}
+		for _, k := range val.sortedKeys() {
+			key := fmt.Sprintf("%v", k)
+			childInSchema := inSchema || key == "schema" ||
+				len(p) == 2 && p[0] == "components" && p[1] == "schemas"
+			resolved, err := f.resolve(val[k], p.child(key), file, childInSchema, stack)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = resolved
+		}
+	}
+	return v, nil
+}
+
+func (f *FileSet) resolveRef(val object, ref string, p _path, file string, inSchema bool, stack []fileRef) (interface{}, error) {
+	target, ok := f.target(file, ref)
+	if !ok {
+		return val, nil
+	}
+	if target.file == f.root {
+		f.replaceRef(val, file, "#"+target.pointer)
+		return val, nil
+	}
+	if !inSchema {
+		return f.inline(val, target, p, false, stack)
+	}
+	name, err := f.externalSchema(target)
+	if err != nil {
+		return nil, err
+	}
+	if len(p) == 3 && p[0] == "components" && p[1] == "schemas" && p[2] == name && !f.added[name] {
+		// The schema in the root document which refers to the file
+		return f.inline(val, target, p, true, stack)
+	}
+	f.replaceRef(val, file, "#/components/schemas/"+name)
+	return val, nil
+}
+
+// resolveMapping replaces the references to other files in the discriminator
+// mapping of schema, in file
+func (f *FileSet) resolveMapping(schema object, file string) error {
+	discriminator, _ := schema["discriminator"].(object)
+	mapping, _ := discriminator["mapping"].(object)
+	for k, v := range mapping {
+		orig, ok := v.(string)
+		if !ok || !isMappingRef(orig) {
+			continue
+		}
+		target, ok := f.target(file, orig)
+		if !ok {
+			continue
+		}
+		ref := "#" + target.pointer
+		if target.file != f.root {
+			name, err := f.externalSchema(target)
+			if err != nil {
+				return err
+			}
+			ref = "#/components/schemas/" + name
+		}
+		if ref != orig {
+			f.recordRef(file, ref, orig)
+			mapping[k] = ref
+		}
+	}
+	return nil
+}
+
+// isMappingRef reports whether a value of a discriminator mapping is a
+// reference rather than the name of a schema
+func isMappingRef(value string) bool {
+	switch strings.ToLower(path.Ext(value)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return strings.ContainsAny(value, "#/")
+}
+
+// replaceRef replaces the reference of val in file, recording the original
+func (f *FileSet) replaceRef(val object, file, ref string) {
+	if val["$ref"] == ref {
+		return
+	}
+	f.recordRef(file, ref, fmt.Sprintf("%v", val["$ref"]))
+	val["$ref"] = ref
+}
+
+// recordRef records orig as the reference in file replaced by ref
+func (f *FileSet) recordRef(file, ref, orig string) {
+	if f.refs[file] == nil {
+		f.refs[file] = map[string]string{}
+	}
+	f.refs[file][ref] = orig
+}
+
+// inline returns the content of target to replace the reference val
+func (f *FileSet) inline(val object, target fileRef, p _path, inSchema bool, stack []fileRef) (interface{}, error) {
+	for _, r := range stack {
+		if r == target {
+			return nil, fmt.Errorf("%s: circular reference to #%s", target.file, target.pointer)
+		}
+	}
+	content, err := f.content(target)
+	if err != nil {
+		return nil, err
+	}
+	f.inlined = append(f.inlined, inlined{path: p, target: target, ref: copyObject(val)})
+	return f.resolve(content, p, target.file, inSchema, append(stack, target))
+}
+
+// externalSchema returns the name in components.schemas of the schema at
+// target, adding it if it is not already there.
+func (f *FileSet) externalSchema(target fileRef) (string, error) {
+	if name, ok := f.names[target]; ok {
+		return name, nil
+	}
+	schemas, err := f.Merged.schemasNode()
+	if err != nil {
+		return "", err
+	}
+	name, err := resolveCollision(f.resolver, externalName(target), func() (NameData, error) {
+		return f.externalNameData(target), nil
+	}, func(name string) bool {
+		_, ok := schemas[name]
+		return ok || f.external[name] != (fileRef{})
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", target.file, err)
+	}
+	// The name is recorded first so that recursive references find it
+	f.names[target] = name
+	f.external[name] = target
+	f.added[name] = true
+	content, err := f.content(target)
+	if err != nil {
+		return "", err
+	}
+	schemas[name], err = f.resolve(content, _path{"components", "schemas", name}, target.file, true, nil)
+	return name, err
+}
+
+// externalName names a schema in another file by the last segment of its
+// pointer, or by the name of the file if it is the whole file.
+func externalName(target fileRef) string {
+	segments := strings.Split(target.pointer, "/")
+	name := toIdentifier(unescapePointer(segments[len(segments)-1]))
+	if target.pointer == "" || name == "" {
+		base := path.Base(target.file)
+		name = sanitizeURLPath(strings.TrimSuffix(base, path.Ext(base)))
+	}
+	if name == "" {
+		return "Schema"
+	}
+	return name
+}
+
+// externalNameData describes a schema in another file by the directories
+// from the root document to the file, e.g. [V2 Schemas] for v2/schemas/pet.yaml
+func (f *FileSet) externalNameData(target fileRef) NameData {
+	var segments []string
+	dir, err := filepath.Rel(filepath.FromSlash(path.Dir(f.root)), filepath.FromSlash(path.Dir(target.file)))
+	if err == nil {
+		for _, segment := range strings.Split(filepath.ToSlash(dir), "/") {
+			if name := sanitizeURLPath(segment); name != "" {
+				segments = append(segments, name)
+			}
+		}
+	}
+	return NameData{Path: strings.Join(segments, ""), PathSegments: segments, Name: externalName(target)}
+}
+
+// Split returns the content of each file changed in merged, a transformed
+// copy of the merged document, by name. Schemas extracted from any file are
+// in components.schemas of the root document.
+func (f *FileSet) Split(merged Spec) (map[string]*Spec, error) {
+	result := copyObject(merged.object)
+	// restored holds the original references put back, which are already
+	// relative to their file
+	restored := map[uintptr]bool{}
+	type write struct {
+		target  fileRef
+		content interface{}
+	}
+	var writes []write
+
+	// Innermost first, so that the content of each is complete
+	for i := len(f.inlined) - 1; i >= 0; i-- {
+		in := f.inlined[i]
+		content, ok := lookupPath(result, in.path)
+		if !ok {
+			return nil, fmt.Errorf("%s: content moved from %s", in.target.file, in.path.pointer())
+		}
+		ref := copyObject(in.ref)
+		restored[reflect.ValueOf(ref).Pointer()] = true
+		if err := setPath(result, in.path, ref); err != nil {
+			return nil, err
+		}
+		writes = append(writes, write{target: in.target, content: content})
+	}
+	if schemas, ok := lookupPath(result, _path{"components", "schemas"}); ok {
+		schemas, _ := schemas.(object)
+		for _, name := range sortedNames(f.added) {
+			writes = append(writes, write{target: f.external[name], content: schemas[name]})
+			delete(schemas, name)
+		}
+		f.removeEmptySchemas(result, schemas)
+	}
+
+	f.rewriteRefs(result, f.root, restored)
+	files := map[string]object{f.root: result}
+	// Outermost first, so that content within other content is kept
+	sort.SliceStable(writes, func(i, j int) bool {
+		return len(writes[i].target.pointer) < len(writes[j].target.pointer)
+	})
+	written := map[fileRef]interface{}{}
+	for i := len(writes) - 1; i >= 0; i-- {
+		w := writes[i]
+		f.rewriteRefs(w.content, w.target.file, restored)
+		if prev, ok := written[w.target]; ok && !reflect.DeepEqual(prev, w.content) {
+			return nil, fmt.Errorf("%s: content at #%s changed differently where it is used", w.target.file, w.target.pointer)
+		}
+		written[w.target] = w.content
+	}
+	for _, w := range writes {
+		file, ok := files[w.target.file]
+		if !ok {
+			file = copyObject(f.files[w.target.file].object)
+		}
+		if w.target.pointer == "" {
+			content, ok := written[w.target].(object)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected mapping at top level", w.target.file)
+			}
+			file = content
+		} else if err := setPath(file, pointerPath(w.target.pointer), written[w.target]); err != nil {
+			return nil, fmt.Errorf("%s: %w", w.target.file, err)
+		}
+		files[w.target.file] = file
+	}
+
+	ret := map[string]*Spec{}
+	for name, content := range files {
+		orig := f.files[name]
+		if !reflect.DeepEqual(orig.object, content) {
+			ret[name] = &Spec{object: content, node: orig.node, indent: orig.indent, json: orig.json, text: orig.text}
+			if name == f.root {
+				// Schemas extracted from other files keep their formatting
+				ret[name].origins = merged.origins
+				ret[name].source = merged.node
+			}
+		}
+	}
+	return ret, nil
+}
+
+// removeEmptySchemas removes components.schemas, and then components, if they
+// are empty and were not in the root document.
+func (f *FileSet) removeEmptySchemas(result object, schemas object) {
+	root := f.files[f.root].object
+	if len(schemas) > 0 || len(root.findPath(_path{"components", "schemas"}, nil)) > 0 {
+		return
+	}
+	components, _ := result["components"].(object)
+	delete(components, "schemas")
+	if _, ok := root["components"]; !ok && len(components) == 0 {
+		delete(result, "components")
+	}
+}
+
+// rewriteRefs makes the references within v, which is written to file,
+// relative to that file.
+func (f *FileSet) rewriteRefs(v interface{}, file string, restored map[uintptr]bool) {
+	switch val := v.(type) {
+	case []interface{}:
+		for _, item := range val {
+			f.rewriteRefs(item, file, restored)
+		}
+	case object:
+		if restored[reflect.ValueOf(val).Pointer()] {
+			return
+		}
+		if ref, ok := val["$ref"].(string); ok && strings.HasPrefix(ref, "#") {
+			val["$ref"] = f.relativeRef(file, ref)
+		}
+		if discriminator, ok := val["discriminator"].(object); ok {
+			mapping, _ := discriminator["mapping"].(object)
+			for k, v := range mapping {
+				if ref, ok := v.(string); ok && strings.HasPrefix(ref, "#") {
+					mapping[k] = f.relativeRef(file, ref)
+				}
+			}
+		}
+		for _, item := range val {
+			f.rewriteRefs(item, file, restored)
+		}
+	}
+}
+
+// relativeRef returns the reference from file to the content at ref in the
+// merged document
+func (f *FileSet) relativeRef(file, ref string) string {
+	if orig, ok := f.refs[file][ref]; ok {
+		return orig
+	}
+	if name := strings.TrimPrefix(ref, "#/components/schemas/"); name != ref {
+		if target, ok := f.external[name]; ok && (f.added[name] || file != f.root) {
+			return relativeRef(file, target)
+		}
+	}
+	if file == f.root {
+		return ref
+	}
+	return relativeRef(file, fileRef{file: f.root, pointer: strings.TrimPrefix(ref, "#")})
+}
+
+func relativeRef(from string, to fileRef) string {
+	ret := ""
+	if to.file != from {
+		rel, err := filepath.Rel(filepath.FromSlash(path.Dir(from)), filepath.FromSlash(to.file))
+		if err != nil {
+			rel = to.file
+		}
+		ret = filepath.ToSlash(rel)
+	}
+	if to.pointer != "" || ret == "" {
+		ret += "#" + to.pointer
+	}
+	return ret
+}
+
+func sortedNames(names map[string]bool) []string {
+	ret := make([]string, 0, len(names))
+	for name := range names {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func unescapePointer(segment string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+}
+
+// pointerPath returns the path of a JSON pointer, e.g. /paths/~1pets
+func pointerPath(pointer string) _path {
+	if pointer == "" || pointer == "/" {
+		return _path{}
+	}
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	ret := make(_path, len(segments))
+	for i, segment := range segments {
+		ret[i] = unescapePointer(segment)
+	}
+	return ret
+}
+
+func lookupPointer(v interface{}, pointer string) (interface{}, bool) {
+	return lookupPath(v, pointerPath(pointer))
+}
+
+// lookupPath returns the value at path within v
+func lookupPath(v interface{}, p _path) (interface{}, bool) {
+	for _, key := range p {
+		switch val := v.(type) {
+		case object:
+			child, ok := val[val.key(key)]
+			if !ok {
+				return nil, false
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(val) {
+				return nil, false
+			}
+			v = val[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// setPath sets the value at path within v, whose parent must exist
+func setPath(v interface{}, p _path, value interface{}) error {
+	if len(p) == 0 {
+		return fmt.Errorf("cannot replace the whole document")
+	}
+	parent, ok := lookupPath(v, p[:len(p)-1])
+	if !ok {
+		return fmt.Errorf("no content at %s", p[:len(p)-1].pointer())
+	}
+	key := p[len(p)-1]
+	switch val := parent.(type) {
+	case object:
+		val[val.key(key)] = value
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(val) {
+			return fmt.Errorf("no content at %s", p.pointer())
+		}
+		val[i] = value
+	default:
+		return fmt.Errorf("no content at %s", p.pointer())
+	}
+	return nil
+}
+
+// key returns the key of o written as key, which may be an integer such as a
+// status code
+func (o object) key(key string) interface{} {
+	if _, ok := o[key]; ok {
+		return key
+	}
+	if i, err := strconv.Atoi(key); err == nil {
+		if _, ok := o[i]; ok {
+			return i
+		}
+	}
+	return key
+}
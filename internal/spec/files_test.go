@@ -0,0 +1,210 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseFiles(files map[string]string) func(name string) (*Spec, error) {
+	return func(name string) (*Spec, error) {
+		content, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: not found", name)
+		}
+		return NewFromYaml(strings.NewReader(content))
+	}
+}
+
+func TestFileSet(t *testing.T) {
+	files := map[string]string{
+		"api/openapi.yaml": `
+openapi: 3.0.0
+paths:
+  /pets:
+    $ref: paths/pets.yaml
+components:
+  schemas:
+    Pet:
+      $ref: schemas/pet.yaml
+`,
+		"api/paths/pets.yaml": `
+get:
+  parameters:
+    - $ref: '../parameters.yaml#/limit'
+  responses:
+    200:
+      content:
+        application/json:
+          schema:
+            type: array
+            items:
+              $ref: ../schemas/pet.yaml
+post:
+  requestBody:
+    content:
+      application/json:
+        schema:
+          type: object
+          properties:
+            name: {type: string}
+  responses:
+    201:
+      content:
+        application/json:
+          schema:
+            $ref: '../openapi.yaml#/components/schemas/Pet'
+`,
+		"api/parameters.yaml": `
+limit:
+  name: limit
+  in: query
+  schema: {type: integer}
+`,
+		"api/schemas/pet.yaml": `
+type: object
+properties:
+  owner:
+    $ref: owner.yaml
+  tag:
+    type: object
+    properties:
+      label: {type: string}
+`,
+		"api/schemas/owner.yaml": `
+type: object
+properties:
+  id: {type: string}
+`,
+	}
+	set, err := LoadFiles("api/openapi.yaml", parseFiles(files), Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"api/openapi.yaml", "api/parameters.yaml", "api/paths/pets.yaml", "api/schemas/owner.yaml", "api/schemas/pet.yaml",
+	}, set.Files())
+
+	get := func(s Spec, pointer string) interface{} {
+		v, ok := lookupPointer(s.object, pointer)
+		assert.True(t, ok, pointer)
+		return v
+	}
+	assert.Equal(t, "limit", get(set.Merged, "/paths/~1pets/get/parameters/0/name"))
+	assert.Equal(t, object{"$ref": "#/components/schemas/Owner"}, get(set.Merged, "/components/schemas/Pet/properties/owner"))
+	assert.Equal(t, object{"type": "string"}, get(set.Merged, "/components/schemas/Owner/properties/id"))
+
+	out, _, err := set.Merged.Transform(Options{})
+	assert.NoError(t, err)
+	split, err := set.Split(out)
+	assert.NoError(t, err)
+	assert.Len(t, split, 3)
+
+	root := *split["api/openapi.yaml"]
+	assert.Equal(t, object{"$ref": "paths/pets.yaml"}, get(root, "/paths/~1pets"))
+	assert.Equal(t, object{"$ref": "schemas/pet.yaml"}, get(root, "/components/schemas/Pet"))
+	assert.Equal(t, object{"$ref": "#/components/schemas/Pet"}, get(root, "/components/schemas/GetPets200Response/items"))
+	assert.Equal(t, object{"label": object{"type": "string"}}, get(root, "/components/schemas/PetTag/properties"))
+	assert.NotContains(t, get(root, "/components/schemas"), "Owner")
+
+	paths := *split["api/paths/pets.yaml"]
+	assert.Equal(t, object{"$ref": "../parameters.yaml#/limit"}, get(paths, "/get/parameters/0"))
+	assert.Equal(t, object{"$ref": "../openapi.yaml#/components/schemas/GetPets200Response"},
+		get(paths, "/get/responses/200/content/application~1json/schema"))
+	assert.Equal(t, object{"$ref": "../openapi.yaml#/components/schemas/PostPetsRequest"},
+		get(paths, "/post/requestBody/content/application~1json/schema"))
+	assert.Equal(t, object{"$ref": "../openapi.yaml#/components/schemas/Pet"},
+		get(paths, "/post/responses/201/content/application~1json/schema"))
+
+	pet := *split["api/schemas/pet.yaml"]
+	assert.Equal(t, object{"$ref": "owner.yaml"}, get(pet, "/properties/owner"))
+	assert.Equal(t, object{"$ref": "../openapi.yaml#/components/schemas/PetTag"}, get(pet, "/properties/tag"))
+
+	var sb strings.Builder
+	assert.NoError(t, pet.ToYaml(&sb))
+	assert.Equal(t, `
+type: object
+properties:
+  owner:
+    $ref: owner.yaml
+  tag:
+    $ref: ../openapi.yaml#/components/schemas/PetTag
+`, sb.String())
+
+	// Schemas extracted from other files keep their formatting
+	sb.Reset()
+	assert.NoError(t, root.ToYaml(&sb))
+	assert.Contains(t, sb.String(), `
+    PetTag:
+      type: object
+      properties:
+        label: {type: string}
+`)
+
+	t.Run("root without components", func(t *testing.T) {
+		files := map[string]string{
+			"openapi.yaml": "paths:\n  /pets:\n    $ref: pets.yaml\n",
+			"pets.yaml": `
+get:
+  responses:
+    200:
+      content:
+        application/json:
+          schema:
+            $ref: pet.yaml
+`,
+			"pet.yaml": `# a pet
+type: object
+required: [name]
+properties:
+  name: {type: string}
+`,
+		}
+		set, err := LoadFiles("openapi.yaml", parseFiles(files), Options{})
+		assert.NoError(t, err)
+		var sb strings.Builder
+		assert.NoError(t, set.Merged.ToYaml(&sb))
+		assert.Contains(t, sb.String(), `
+components:
+  schemas:
+    Pet:
+      # a pet
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+`)
+	})
+}
+
+func TestLoadFiles_errors(t *testing.T) {
+	tests := map[string]struct {
+		files   map[string]string
+		wantErr string
+	}{
+		"missing file": {
+			files:   map[string]string{"openapi.yaml": "paths:\n  /pets:\n    $ref: pets.yaml\n"},
+			wantErr: "pets.yaml: not found",
+		},
+		"missing pointer": {
+			files: map[string]string{
+				"openapi.yaml": "paths:\n  /pets:\n    $ref: 'pets.yaml#/pets'\n",
+				"pets.yaml":    "dogs: {}\n",
+			},
+			wantErr: "pets.yaml: no content at #/pets",
+		},
+		"circular": {
+			files: map[string]string{
+				"openapi.yaml": "paths:\n  /pets:\n    $ref: pets.yaml\n",
+				"pets.yaml":    "get:\n  $ref: pets.yaml\n",
+			},
+			wantErr: "pets.yaml: circular reference to #",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadFiles("openapi.yaml", parseFiles(tt.files), Options{})
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package spec
+
+import (
+	"strings"
+	"unicode"
+)
+
+// reservedWords are the keywords of the languages commonly generated from
+// openapi (Go, TypeScript and Java), which cannot be used as type names.
+var reservedWords = map[string]bool{}
+
+func init() {
+	for _, words := range []string{
+		// Go
+		"break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var",
+		// TypeScript
+		"any as boolean catch class debugger delete do enum export extends false finally function implements in instanceof let new null number of private protected public static string super symbol this throw true try typeof void while with yield",
+		// Java
+		"abstract assert byte char double final float int long native short strictfp synchronized throws transient volatile",
+	} {
+		for _, word := range strings.Fields(words) {
+			reservedWords[word] = true
+		}
+	}
+}
+
+// sanitizeURLPath converts a path, or any other name, to the form used
+// within symbols: each word is capitalized and joined, and path parameters
+// become By{Name}, e.g. /v2/users/{userId}/items.json becomes
+// V2UsersByUserIdItemsJson.
+func sanitizeURLPath(in string) string {
+	var sb strings.Builder
+	for _, word := range identifierWords(in, false) {
+		sb.WriteString(capitalizeFirst(word))
+	}
+	return sb.String()
+}
+
+// identifierWords splits in into words at each character which cannot appear
+// in an identifier, and also at underscores unless keepUnderscore is set. A
+// path parameter such as {userId} gives the words By and userId.
+func identifierWords(in string, keepUnderscore bool) []string {
+	ret := []string{}
+	var word []rune
+	endWord := func() {
+		if len(word) > 0 {
+			ret = append(ret, string(word))
+			word = nil
+		}
+	}
+	for _, r := range in {
+		switch {
+		case r == '{':
+			endWord()
+			ret = append(ret, "By")
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || (keepUnderscore && r == '_'):
+			word = append(word, r)
+		default:
+			endWord()
+		}
+	}
+	endWord()
+	return ret
+}
+
+// toIdentifier makes name a valid identifier in the generated code of any of
+// the common target languages. Invalid characters are removed, with the
+// following letter capitalized, a leading digit is prefixed with N and a
+// reserved word is suffixed with Schema. It returns "" if name contains no
+// letters or digits.
+func toIdentifier(name string) string {
+	words := identifierWords(name, true)
+	if len(words) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for i, word := range words {
+		if i > 0 {
+			word = capitalizeFirst(word)
+		}
+		sb.WriteString(word)
+	}
+	ret := sb.String()
+	if unicode.IsDigit([]rune(ret)[0]) {
+		ret = "N" + ret
+	}
+	if reservedWords[ret] {
+		ret += "Schema"
+	}
+	return ret
+}
+
+func capitalizeFirst(in string) string {
+	if in == "" {
+		return ""
+	}
+	r := []rune(in)
+	return string(unicode.ToUpper(r[0])) + string(r[1:])
+}
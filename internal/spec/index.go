@@ -0,0 +1,58 @@
+package spec
+
+import "fmt"
+
+// schemaIndex finds the schemas in components.schemas by the hash of their
+// canonical form, so that a matching schema is found without comparing every
+// schema.
+type schemaIndex struct {
+	compare *canonicalizer
+	schemas object
+	// names holds the names of the schemas with each hash
+	names map[string]map[string]bool
+	// hashes holds the hash of each schema by name
+	hashes map[string]string
+}
+
+func newSchemaIndex(compare *canonicalizer, schemas object) *schemaIndex {
+	ret := &schemaIndex{
+		compare: compare,
+		schemas: schemas,
+		names:   map[string]map[string]bool{},
+		hashes:  map[string]string{},
+	}
+	for name := range schemas {
+		ret.update(fmt.Sprintf("%v", name))
+	}
+	return ret
+}
+
+// update indexes the current content of the named schema
+func (i *schemaIndex) update(name string) {
+	if hash, ok := i.hashes[name]; ok {
+		delete(i.names[hash], name)
+		delete(i.hashes, name)
+	}
+	schema, ok := i.schemas[name].(object)
+	if !ok {
+		return
+	}
+	hash := i.compare.canonicalNamed(name, schema)
+	if i.names[hash] == nil {
+		i.names[hash] = map[string]bool{}
+	}
+	i.names[hash][name] = true
+	i.hashes[name] = hash
+}
+
+// find returns the name of a schema which is the same as obj, the first in
+// sorted order if there is more than one, or "" if there is none.
+func (i *schemaIndex) find(obj object) string {
+	ret := ""
+	for name := range i.names[i.compare.canonical(obj)] {
+		if ret == "" || name < ret {
+			ret = name
+		}
+	}
+	return ret
+}
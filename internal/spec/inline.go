@@ -0,0 +1,166 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InlineOptions controls the behaviour of Inline. The zero value inlines every
+// schema referenced once and logs nothing.
+type InlineOptions struct {
+	// Schemas are the schemas to inline wherever they are referenced. If
+	// empty, every schema referenced exactly once is inlined.
+	Schemas []string
+	// Log receives progress messages, if set.
+	Log io.Writer
+}
+
+// Inlining describes a schema which has been moved from components.schemas
+// to where it was referenced
+type Inlining struct {
+	// Schema is the name the schema had in components.schemas
+	Schema string `json:"schema"`
+	// Pointers are JSON pointers to each reference replaced by the schema,
+	// in the original document
+	Pointers []string `json:"pointers"`
+}
+
+// Inline is the inverse of Transform: it replaces references to schemas in
+// components.schemas with the schemas themselves, and removes the schemas.
+// Schemas which refer to themselves, are in a discriminator mapping, or have
+// content referred to are left as they are.
+func (s Spec) Inline(opts InlineOptions) (Spec, []Inlining, error) {
+	log := opts.Log
+	if log == nil {
+		log = io.Discard
+	}
+	ret := s.copy()
+	found, _ := lookupPath(ret.object, _path{"components", "schemas"})
+	schemas, _ := found.(object)
+	for _, name := range opts.Schemas {
+		if _, ok := schemas[name].(object); !ok {
+			return s, nil, fmt.Errorf("unknown schema %q", name)
+		}
+	}
+
+	refs := findSchemaRefs(ret.object, nil)
+	graph := newSchemaGraph(refs)
+	uses := map[string][]schemaRef{}
+	kept := map[string]string{}
+	for _, r := range refs {
+		switch {
+		case r.holder == nil:
+			kept[r.name] = "it is in a discriminator mapping"
+		case !r.whole:
+			kept[r.name] = "content within it is referenced"
+		case len(r.holder) > 1:
+			kept[r.name] = "a reference to it has other keys"
+		default:
+			uses[r.name] = append(uses[r.name], r)
+		}
+	}
+
+	selected := toSet(opts.Schemas)
+	var names []string
+	for _, k := range schemas.sortedKeys() {
+		name := fmt.Sprintf("%v", k)
+		if _, ok := schemas[k].(object); !ok || len(uses[name]) == 0 {
+			continue
+		}
+		if len(selected) > 0 && !selected[name] || len(selected) == 0 && len(uses[name]) != 1 {
+			continue
+		}
+		reason := kept[name]
+		if reason == "" && graph.cyclic(name) {
+			reason = "it refers to itself"
+		}
+		if reason != "" {
+			fmt.Fprintf(log, "Skipping %s since %s\n", name, reason)
+			continue
+		}
+		names = append(names, name)
+	}
+
+	// Each schema is complete before it is copied to where it is referenced
+	var inlinings []Inlining
+	var inlined []inlinedRef
+	for _, name := range graph.dependenciesFirst(names) {
+		schema := schemas[name].(object)
+		inlining := Inlining{Schema: name}
+		for i, r := range uses[name] {
+			content := schema
+			if i > 0 {
+				content = copyObject(schema)
+			}
+			delete(r.holder, "$ref")
+			for k, v := range content {
+				r.holder[k] = v
+			}
+			inlined = append(inlined, inlinedRef{name: name, holder: r.holder})
+			inlining.Pointers = append(inlining.Pointers, r.path.pointer())
+		}
+		delete(schemas, name)
+		fmt.Fprintf(log, "Inlined %s at %d locations\n", name, len(uses[name]))
+		inlinings = append(inlinings, inlining)
+	}
+	if len(inlinings) == 0 {
+		return ret, nil, nil
+	}
+	ret.removeSchemasIfEmpty()
+	ret.graftInlined(s, inlined)
+	return ret, inlinings, nil
+}
+
+// graftInlined gives each schema inlined at a reference the original node of
+// the schema, so that it keeps its formatting. orig is the document before
+// inlining.
+func (s *Spec) graftInlined(orig Spec, inlined []inlinedRef) {
+	if s.node == nil {
+		return
+	}
+	paths := map[uintptr]_path{}
+	objectPaths(s.object, nil, paths)
+	// Outermost first, so that schemas inlined within others are grafted
+	// into their copies
+	sort.SliceStable(inlined, func(i, j int) bool {
+		return len(paths[reflect.ValueOf(inlined[i].holder).Pointer()]) < len(paths[reflect.ValueOf(inlined[j].holder).Pointer()])
+	})
+	if s.source == nil {
+		// Schemas extracted before keep finding their original nodes
+		s.source = orig.node
+	}
+	s.node = copyNode(s.node, map[*yaml.Node]*yaml.Node{})
+	for _, in := range inlined {
+		p, ok := paths[reflect.ValueOf(in.holder).Pointer()]
+		n := orig.originalNode(_path{"components", "schemas", in.name})
+		if ok && n != nil {
+			graftNode(s.node, p, copyNode(n, map[*yaml.Node]*yaml.Node{}))
+		}
+	}
+}
+
+// inlinedRef is a reference replaced by the named schema
+type inlinedRef struct {
+	name   string
+	holder object
+}
+
+// objectPaths records the path of each object within v, at path, by identity
+func objectPaths(v interface{}, path _path, paths map[uintptr]_path) {
+	switch val := v.(type) {
+	case []interface{}:
+		for i, item := range val {
+			objectPaths(item, path.child(strconv.Itoa(i)), paths)
+		}
+	case object:
+		paths[reflect.ValueOf(val).Pointer()] = path
+		for k, item := range val {
+			objectPaths(item, path.child(fmt.Sprintf("%v", k)), paths)
+		}
+	}
+}
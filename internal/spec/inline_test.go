@@ -0,0 +1,156 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpec_Inline_roundTrip(t *testing.T) {
+	input := `openapi: 3.0.0
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              # the new pet
+              type: object
+              required: [name]
+              properties:
+                name: {type: string}
+                tag:
+                  type: object
+                  properties:
+                    label: {type: string}
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  type: object
+                  properties:
+                    id: {type: integer}
+`
+	s, err := NewFromYaml(strings.NewReader(input))
+	assert.NoError(t, err)
+	extracted, extractions, err := s.Transform(Options{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, extractions)
+
+	inlined, inlinings, err := extracted.Inline(InlineOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, inlinings, len(extractions))
+	assert.Equal(t, s.object, inlined.object)
+	var sb strings.Builder
+	assert.NoError(t, inlined.ToYaml(&sb))
+	assert.Equal(t, input, sb.String())
+}
+
+func TestSpec_Inline(t *testing.T) {
+	input := `
+paths:
+  /pets:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pets'
+        default:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Error'
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+      responses:
+        default:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Error'
+components:
+  schemas:
+    Pets:
+      type: array
+      items:
+        $ref: '#/components/schemas/Pet'
+    Pet:
+      oneOf:
+        - $ref: '#/components/schemas/Cat'
+      discriminator:
+        propertyName: kind
+        mapping:
+          cat: '#/components/schemas/Cat'
+    Cat:
+      type: object
+      properties:
+        kind: {type: string}
+    Error:
+      type: object
+      properties:
+        message: {type: string}
+    Node:
+      type: object
+      properties:
+        children:
+          type: array
+          items:
+            $ref: '#/components/schemas/Node'
+`
+	tests := map[string]struct {
+		opts    InlineOptions
+		want    []Inlining
+		kept    []string
+		wantErr string
+	}{
+		"single use": {
+			want: []Inlining{{Schema: "Pets", Pointers: []string{
+				"/paths/~1pets/get/responses/200/content/application~1json/schema",
+			}}},
+			kept: []string{"Cat", "Error", "Node", "Pet"},
+		},
+		"selected": {
+			opts: InlineOptions{Schemas: []string{"Error", "Node"}},
+			want: []Inlining{{Schema: "Error", Pointers: []string{
+				"/paths/~1pets/get/responses/default/content/application~1json/schema",
+				"/paths/~1pets/post/responses/default/content/application~1json/schema",
+			}}},
+			kept: []string{"Cat", "Node", "Pet", "Pets"},
+		},
+		"unknown": {
+			opts:    InlineOptions{Schemas: []string{"Dog"}},
+			wantErr: `unknown schema "Dog"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(input))
+			assert.NoError(t, err)
+			out, inlinings, err := s.Inline(tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, inlinings)
+			schemas, err := out.schemasNode()
+			assert.NoError(t, err)
+			var kept []string
+			for _, k := range schemas.sortedKeys() {
+				kept = append(kept, k.(string))
+			}
+			assert.Equal(t, tt.kept, kept)
+		})
+	}
+}
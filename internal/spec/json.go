@@ -0,0 +1,97 @@
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NewFromJSON reads a JSON document. The document is built as a tree of yaml
+// nodes, in the same form as yaml would parse it, so that key order is
+// preserved in the same way.
+func NewFromJSON(reader io.Reader) (*Spec, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	root, err := jsonNode(decoder)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("offset %d: unexpected content after JSON value", decoder.InputOffset())
+	}
+	ret, err := newFromDocument(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}})
+	if err != nil {
+		return nil, err
+	}
+	ret.json = true
+	return ret, nil
+}
+
+// jsonNode reads the next JSON value from decoder as a yaml node, styled as
+// yaml would parse the same JSON text.
+func jsonNode(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch val := token.(type) {
+	case json.Delim:
+		switch val {
+		case '{':
+			ret := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle}
+			for decoder.More() {
+				key, err := jsonNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				value, err := jsonNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				ret.Content = append(ret.Content, key, value)
+			}
+			_, err = decoder.Token()
+			return ret, err
+		case '[':
+			ret := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+			for decoder.More() {
+				item, err := jsonNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				ret.Content = append(ret.Content, item)
+			}
+			_, err = decoder.Token()
+			return ret, err
+		}
+		return nil, fmt.Errorf("offset %d: unexpected %v", decoder.InputOffset(), val)
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.DoubleQuotedStyle, Value: val}, nil
+	case json.Number:
+		// Tagged as yaml would resolve the same literal, e.g. !!int for 12
+		ret := &yaml.Node{Kind: yaml.ScalarNode, Value: val.String()}
+		ret.Tag = ret.ShortTag()
+		return ret, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprintf("%v", val)}, nil
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+}
+
+func (s Spec) ToJSON(writer io.Writer) error {
+	node, err := s.toNode()
+	if err != nil {
+		return err
+	}
+	return writeJSONNode(writer, node, "")
+}
@@ -0,0 +1,326 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// defaultNameHintKeys are the keys of a schema which suggest its name, in
+// order of preference
+var defaultNameHintKeys = []string{"x-schema-name", "x-go-name", "title"}
+
+// NameData is the data available to a naming template. Each field is empty
+// where it does not apply to the location, or where the uses of a shared
+// schema have different values.
+type NameData struct {
+	// Verb is the HTTP method of the operation, e.g. Post
+	Verb string
+	// Path is the endpoint of the operation, e.g. V2Pets for /v2/pets
+	Path string
+	// PathSegments are the segments of the endpoint, e.g. [V2 Pets]
+	PathSegments []string
+	// OperationID is the operationId of the operation, e.g. CreatePet for
+	// create-pet
+	OperationID string
+	// StatusCode is the response status code, or {prefix}xx for status codes
+	// with the same first digit
+	StatusCode string
+	// Property is the nearest property of the parent schema containing an
+	// embedded schema, e.g. Address
+	Property string
+	// Parent is the schema containing an embedded schema, or the response
+	// containing a header in components.responses, as an identifier
+	Parent string
+	// Subschema is the name of an embedded schema within its parent, e.g.
+	// TagsItem for the items of property tags, or Item for the items of an
+	// array body or parameter
+	Subschema string
+	// Name is the name of the parameter, header, callback or component, or
+	// the variant of a composition
+	Name string
+	// Common is set if the schema is shared by more than one use which
+	// cannot be told apart by the default naming rules
+	Common bool
+	// Default is the name given by the default naming rules
+	Default string
+}
+
+// naming holds the parsed naming templates of each location
+type naming map[Location]*template.Template
+
+func parseNaming(templates map[Location]string) (naming, error) {
+	ret := naming{}
+	for location, text := range templates {
+		if !isLocation(location) {
+			return nil, fmt.Errorf("naming template for unknown location %q", location)
+		}
+		tmpl, err := template.New(string(location)).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("naming template for %s: %w", location, err)
+		}
+		ret[location] = tmpl
+	}
+	return ret, nil
+}
+
+func isLocation(location Location) bool {
+	for _, e := range append(append([]extraction{}, topLevelExtractions...), embeddedExtractions...) {
+		if e.location == location {
+			return true
+		}
+	}
+	return false
+}
+
+// name returns the name given by the template for location, or the default
+// if there is none.
+func (n naming) name(location Location, data NameData) (string, error) {
+	tmpl, ok := n[location]
+	if !ok {
+		return data.Default, nil
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(sb.String())
+	if name == "" {
+		return "", fmt.Errorf("naming template for %s gave an empty name", location)
+	}
+	return name, nil
+}
+
+// nameHint returns the name suggested by the first of the hint keys set in
+// obj, skipping (and logging) any which are not valid identifiers.
+func (t *transformer) nameHint(obj object, path _path) string {
+	keys := t.opts.NameHintKeys
+	if keys == nil {
+		keys = defaultNameHintKeys
+	}
+	for _, key := range keys {
+		v, ok := obj[key]
+		if !ok {
+			continue
+		}
+		hint, ok := v.(string)
+		if !ok || !isIdentifier(hint) {
+			fmt.Fprintf(t.log, "Ignoring %s %q of %s which is not a valid name\n", key, fmt.Sprintf("%v", v), path.pointer())
+			continue
+		}
+		return hint
+	}
+	return ""
+}
+
+// isIdentifier reports whether name is a letter or underscore followed by
+// letters, digits and underscores
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// operationNameData returns the data for a schema found within an operation
+// at paths.{endpoint}.{verb}, or a path item if verb is false.
+func (s Spec) operationNameData(ps paths, verb bool) (NameData, error) {
+	var ret NameData
+	endpoint, err := ps.commonValueAtIndex(1)
+	if err != nil {
+		return ret, err
+	}
+	if endpoint != "" {
+		ret.Path = sanitizeURLPath(endpoint)
+		ret.PathSegments = pathSegments(endpoint)
+	}
+	if !verb {
+		return ret, nil
+	}
+	v, err := ps.commonValueAtIndex(2)
+	if err != nil {
+		return ret, err
+	}
+	if v != "" {
+		ret.Verb = toTitle(v)
+	}
+	ret.OperationID = sanitizeURLPath(commonValue(s.operationIDs(ps)))
+	return ret, nil
+}
+
+// operationIDs returns the operationId of the operation containing each of
+// ps, found at paths.{endpoint}.{verb}
+func (s Spec) operationIDs(ps paths) []string {
+	ret := make([]string, len(ps))
+	for i, path := range ps {
+		if len(path) >= 3 {
+			ret[i] = s.operationID(path[:3])
+		}
+	}
+	return ret
+}
+
+// operationID returns the operationId of the operation at path, or "" if it
+// has none.
+func (s Spec) operationID(path _path) string {
+	operation, ok := s.lookupObject(path)
+	if !ok {
+		return ""
+	}
+	id, _ := operation["operationId"].(string)
+	return id
+}
+
+func pathSegments(endpoint string) []string {
+	ret := []string{}
+	for _, segment := range strings.Split(endpoint, "/") {
+		if segment != "" {
+			ret = append(ret, sanitizeURLPath(segment))
+		}
+	}
+	return ret
+}
+
+func requestNameData(s Spec, val objectWithPaths) (NameData, error) {
+	return s.operationNameData(val.paths, true)
+}
+
+func responseNameData(s Spec, val objectWithPaths) (NameData, error) {
+	ret, err := s.operationNameData(val.paths, true)
+	if err != nil {
+		return ret, err
+	}
+	ret.StatusCode, err = val.paths.commonStatusCode()
+	return ret, err
+}
+
+func parameterNameData(s Spec, val objectWithPaths) (NameData, error) {
+	ret, err := s.operationNameData(val.paths, true)
+	if err != nil {
+		return ret, err
+	}
+	ret.Name = sanitizeURLPath(commonValue(s.parameterNames(val.paths)))
+	return ret, nil
+}
+
+func pathParameterNameData(s Spec, val objectWithPaths) (NameData, error) {
+	ret, err := s.operationNameData(val.paths, false)
+	if err != nil {
+		return ret, err
+	}
+	ret.Name = sanitizeURLPath(commonValue(s.parameterNames(val.paths)))
+	return ret, nil
+}
+
+func headerNameData(s Spec, val objectWithPaths) (NameData, error) {
+	ret, err := responseNameData(s, val)
+	if err != nil {
+		return ret, err
+	}
+	header, err := val.paths.commonValueAtIndex(6)
+	ret.Name = sanitizeURLPath(header)
+	return ret, err
+}
+
+func callbackNameData(s Spec, val objectWithPaths) (NameData, error) {
+	var ret NameData
+	var names, verbs, statusCodes, ids []string
+	for _, path := range val.paths {
+		idx := 4
+		if path[0] == "components" {
+			idx = 2
+		}
+		if len(path) <= idx+2 {
+			return ret, fmt.Errorf("path too short")
+		}
+		names = append(names, path[idx])
+		verbs = append(verbs, path[idx+2])
+		ids = append(ids, s.operationID(path[:idx+3]))
+		if len(path) > idx+4 && path[idx+3] == "responses" {
+			statusCodes = append(statusCodes, path[idx+4])
+		}
+	}
+	ret.Name = sanitizeURLPath(commonValue(names))
+	if verb := commonValue(verbs); verb != "" {
+		ret.Verb = toTitle(verb)
+	}
+	ret.StatusCode = commonStatusCode(statusCodes)
+	ret.OperationID = sanitizeURLPath(commonValue(ids))
+	return ret, nil
+}
+
+func callbackParameterNameData(s Spec, val objectWithPaths) (NameData, error) {
+	ret, err := callbackNameData(s, val)
+	if err != nil {
+		return ret, err
+	}
+	ret.Name = sanitizeURLPath(commonValue(s.parameterNames(val.paths)))
+	return ret, nil
+}
+
+func componentNameData(s Spec, val objectWithPaths) (NameData, error) {
+	var names []string
+	for _, path := range val.paths {
+		if len(path) < 3 {
+			return NameData{}, fmt.Errorf("path too short")
+		}
+		names = append(names, path[2])
+	}
+	return NameData{Name: sanitizeURLPath(commonValue(names))}, nil
+}
+
+func componentHeaderNameData(s Spec, val objectWithPaths) (NameData, error) {
+	var headers, parents []string
+	for _, path := range val.paths {
+		header := componentHeaderName(path)
+		if header == "" {
+			return NameData{}, fmt.Errorf("path too short")
+		}
+		headers = append(headers, header)
+		if path[1] == "responses" {
+			parents = append(parents, path[2])
+		}
+	}
+	ret := NameData{Name: sanitizeURLPath(commonValue(headers))}
+	if len(parents) == len(val.paths) {
+		ret.Parent = sanitizeURLPath(commonValue(parents))
+	}
+	return ret, nil
+}
+
+func subschemaNameData(s Spec, val objectWithPaths) (NameData, error) {
+	var parents, properties, subschemas, variants []string
+	for _, path := range val.paths {
+		property, err := subschemaProperty(path)
+		if err != nil {
+			return NameData{}, err
+		}
+		subschema, err := subschemaName(path, s.subschemaDiscriminatorValue(path, val.object))
+		if err != nil {
+			return NameData{}, err
+		}
+		parents = append(parents, toIdentifier(path[2]))
+		properties = append(properties, sanitizeURLPath(property))
+		subschemas = append(subschemas, subschema)
+		if isCompositionBranch(path) {
+			variant, err := compositionVariant(path, s.subschemaDiscriminatorValue(path, val.object))
+			if err != nil {
+				return NameData{}, err
+			}
+			variants = append(variants, variant)
+		}
+	}
+	return NameData{
+		Parent:    commonValue(parents),
+		Property:  commonValue(properties),
+		Subschema: commonValue(subschemas),
+		Name:      commonValue(variants),
+	}, nil
+}
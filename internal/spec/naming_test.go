@@ -0,0 +1,362 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpec_Transform_naming(t *testing.T) {
+	in := `
+paths:
+  /v2/pets/{id}:
+    post:
+      operationId: updatePet
+      parameters:
+        - name: filter
+          in: query
+          schema:
+            type: object
+            properties:
+              status: {type: string}
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+                address:
+                  type: object
+                  properties:
+                    street: {type: string}
+      responses:
+        200:
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  id: {type: string}
+  /v2/owners:
+    get:
+      responses:
+        200:
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  owner: {type: string}
+    put:
+      responses:
+        200:
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  owner: {type: string}
+`
+	tests := map[string]struct {
+		naming map[Location]string
+		want   []string
+	}{
+		"default": {
+			want: []string{
+				"PostV2PetsByIdRequest",
+				"CommonV2Owners200Response",
+				"PostV2PetsById200Response",
+				"PostV2PetsByIdFilterParam",
+				"PostV2PetsByIdRequestAddress",
+			},
+		},
+		"operation id": {
+			naming: map[Location]string{
+				LocationRequestBody:    "{{.OperationID}}Body",
+				LocationResponseBody:   "{{if .OperationID}}{{.OperationID}}{{.StatusCode}}{{else}}{{.Path}}{{.StatusCode}}{{end}}",
+				LocationParameter:      "{{.OperationID}}{{.Name}}",
+				LocationEmbeddedObject: "{{.Parent}}{{.Property}}",
+			},
+			want: []string{
+				"UpdatePetBody",
+				"V2Owners200",
+				"UpdatePet200",
+				"UpdatePetFilter",
+				"UpdatePetBodyAddress",
+			},
+		},
+		"path segments and default": {
+			naming: map[Location]string{
+				LocationRequestBody:  "{{range .PathSegments}}{{.}}{{end}}Input",
+				LocationResponseBody: "{{if .Common}}Shared{{.Path}}{{else}}{{.Default}}{{end}}",
+			},
+			want: []string{
+				"V2PetsByIdInput",
+				"SharedV2Owners",
+				"PostV2PetsById200Response",
+				"PostV2PetsByIdFilterParam",
+				"V2PetsByIdInputAddress",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(in))
+			assert.NoError(t, err)
+			_, extractions, err := s.Transform(Options{Naming: tt.naming})
+			assert.NoError(t, err)
+			var got []string
+			for _, e := range extractions {
+				got = append(got, e.Schema)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSpec_Transform_namingOperationID(t *testing.T) {
+	in := `
+paths:
+  /pets:
+    post:
+      operationId: create-pet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+`
+	tests := map[string]struct {
+		opts Options
+		want string
+	}{
+		"template": {
+			opts: Options{Naming: map[Location]string{
+				LocationRequestBody: "{{if .OperationID}}{{.OperationID}}Body{{else}}{{.Default}}{{end}}",
+			}},
+			want: "CreatePetBody",
+		},
+		"use operation id": {
+			opts: Options{UseOperationID: true},
+			want: "CreatePetRequest",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(in))
+			assert.NoError(t, err)
+			_, extractions, err := s.Transform(tt.opts)
+			assert.NoError(t, err)
+			if assert.Len(t, extractions, 1) {
+				assert.Equal(t, tt.want, extractions[0].Schema)
+			}
+		})
+	}
+}
+
+func TestSpec_Transform_namingErrors(t *testing.T) {
+	in := `
+paths:
+  /foo:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+`
+	tests := map[string]struct {
+		naming  map[Location]string
+		wantErr string
+	}{
+		"unknown location": {
+			naming:  map[Location]string{"requestBdy": "{{.Verb}}"},
+			wantErr: `naming template for unknown location "requestBdy"`,
+		},
+		"invalid template": {
+			naming:  map[Location]string{LocationRequestBody: "{{.Verb"},
+			wantErr: "naming template for requestBody: template: requestBody:1: unclosed action",
+		},
+		"unknown field": {
+			naming:  map[Location]string{LocationRequestBody: "{{.Verbs}}"},
+			wantErr: "can't evaluate field Verbs",
+		},
+		"empty name": {
+			naming:  map[Location]string{LocationRequestBody: "{{.OperationID}}"},
+			wantErr: "/paths/~1foo/post/requestBody/content/application~1json/schema: cannot name schema: naming template for requestBody gave an empty name",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(in))
+			assert.NoError(t, err)
+			_, _, err = s.Transform(Options{Naming: tt.naming})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSpec_Transform_useOperationID(t *testing.T) {
+	in := `
+paths:
+  /v2/mandate-imports:
+    post:
+      operationId: createMandateImport
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                id: {type: string}
+      responses:
+        201:
+          description: created
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  status: {type: string}
+    put:
+      operationId: updateMandateImport
+      responses:
+        200:
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  updated: {type: string}
+  /v2/pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+    put:
+      operationId: updatePet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	_, extractions, err := s.Transform(Options{UseOperationID: true})
+	assert.NoError(t, err)
+	got := map[string]Reason{}
+	for _, e := range extractions {
+		got[e.Schema] = e.Reason
+	}
+	assert.Equal(t, map[string]Reason{
+		"CreateMandateImportRequest":     ReasonUnique,
+		"CreateMandateImport201Response": ReasonUnique,
+		"UpdateMandateImport200Response": ReasonUnique,
+		// Shared by an operation without an operationId
+		"CommonV2PetsRequest": ReasonCommonByPath,
+	}, got)
+}
+
+func TestSpec_Transform_nameHints(t *testing.T) {
+	in := `
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              title: NewPet
+              x-go-name: PetInput
+              type: object
+              properties:
+                owner:
+                  title: Pet Owner
+                  type: object
+                  properties:
+                    name: {type: string}
+      responses:
+        200:
+          description: ok
+          content:
+            application/json:
+              schema:
+                x-schema-name: Pet
+                type: object
+                properties:
+                  id: {type: string}
+components:
+  schemas:
+    Pet:
+      type: string
+`
+	tests := map[string]struct {
+		keys       []string
+		want       []string
+		wantReason []Reason
+	}{
+		"default keys": {
+			want:       []string{"PetInput", "PostPet", "PetInputOwner"},
+			wantReason: []Reason{ReasonHint, ReasonHint, ReasonUnique},
+		},
+		"custom keys": {
+			keys:       []string{"title"},
+			want:       []string{"NewPet", "PostPets200Response", "NewPetOwner"},
+			wantReason: []Reason{ReasonHint, ReasonUnique, ReasonUnique},
+		},
+		"disabled": {
+			keys:       []string{},
+			want:       []string{"PostPetsRequest", "PostPets200Response", "PostPetsRequestOwner"},
+			wantReason: []Reason{ReasonUnique, ReasonUnique, ReasonUnique},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(in))
+			assert.NoError(t, err)
+			var log strings.Builder
+			_, extractions, err := s.Transform(Options{NameHintKeys: tt.keys, Log: &log})
+			assert.NoError(t, err)
+			var got []string
+			var gotReason []Reason
+			for _, e := range extractions {
+				got = append(got, e.Schema)
+				gotReason = append(gotReason, e.Reason)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantReason, gotReason)
+			if len(tt.keys) != 0 {
+				assert.Contains(t, log.String(), `Ignoring title "Pet Owner" of /components/schemas/`)
+			}
+		})
+	}
+}
+
+func Test_isIdentifier(t *testing.T) {
+	for name, want := range map[string]bool{
+		"Pet":       true,
+		"_pet2":     true,
+		"Éleveur":   true,
+		"":          false,
+		"2Pet":      false,
+		"Pet Owner": false,
+		"pet-owner": false,
+	} {
+		assert.Equal(t, want, isIdentifier(name), name)
+	}
+}
@@ -0,0 +1,484 @@
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxAliasExpansion limits the number of nodes which aliases may expand to,
+// so that a document such as a "billion laughs" cannot exhaust memory or time
+const maxAliasExpansion = 1000000
+
+// valueFromNode converts a yaml node into the representation used by the rest
+// of the package: object for mappings, []interface{} for sequences and plain
+// values for scalars. Aliases are expanded into independent copies.
+func valueFromNode(n *yaml.Node) (interface{}, error) {
+	d := valueDecoder{expanding: map[*yaml.Node]bool{}}
+	return d.value(n)
+}
+
+// valueDecoder holds the state of valueFromNode
+type valueDecoder struct {
+	// expanding holds the anchored nodes being decoded, which an alias
+	// within them must not refer to
+	expanding map[*yaml.Node]bool
+	// aliases is the depth of aliases being expanded, and expanded the
+	// number of nodes decoded within them
+	aliases, expanded int
+}
+
+func (d *valueDecoder) value(n *yaml.Node) (interface{}, error) {
+	if d.aliases > 0 {
+		d.expanded++
+		if d.expanded > maxAliasExpansion {
+			return nil, fmt.Errorf("line %d: document contains excessive aliasing", n.Line)
+		}
+	}
+	if n.Anchor != "" {
+		if d.expanding[n] {
+			return nil, fmt.Errorf("line %d: anchor %q value contains itself", n.Line, n.Anchor)
+		}
+		d.expanding[n] = true
+		defer delete(d.expanding, n)
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return d.value(n.Content[0])
+	case yaml.MappingNode:
+		ret := make(object, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Tag == "!!merge" {
+				if err := d.mergeInto(ret, n.Content[i+1]); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			k, err := d.value(n.Content[i])
+			if err != nil {
+				return nil, err
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, fmt.Errorf("line %d: unsupported mapping key", n.Content[i].Line)
+			}
+			v, err := d.value(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			ret[k] = v
+		}
+		return ret, nil
+	case yaml.SequenceNode:
+		ret := make([]interface{}, len(n.Content))
+		for i, item := range n.Content {
+			v, err := d.value(item)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = v
+		}
+		return ret, nil
+	case yaml.AliasNode:
+		if n.Alias == nil {
+			return nil, fmt.Errorf("line %d: unknown anchor %q", n.Line, n.Value)
+		}
+		if d.expanding[n.Alias] {
+			return nil, fmt.Errorf("line %d: anchor %q value contains itself", n.Line, n.Value)
+		}
+		d.aliases++
+		defer func() { d.aliases-- }()
+		return d.value(n.Alias)
+	}
+	// Keep timestamps as written rather than converting them to time.Time
+	if n.ShortTag() == "!!timestamp" {
+		return n.Value, nil
+	}
+	var ret interface{}
+	if err := n.Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// mergeInto applies a yaml merge key ('<<'), without overriding keys which are
+// already set.
+func (d *valueDecoder) mergeInto(o object, n *yaml.Node) error {
+	if n.Kind == yaml.SequenceNode {
+		for _, item := range n.Content {
+			if err := d.mergeInto(o, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	v, err := d.value(n)
+	if err != nil {
+		return err
+	}
+	merged, ok := v.(object)
+	if !ok {
+		return fmt.Errorf("line %d: merge value is not a mapping", n.Line)
+	}
+	for k, item := range merged {
+		if _, exists := o[k]; !exists {
+			o[k] = item
+		}
+	}
+	return nil
+}
+
+// newNode encodes a value as a yaml node. Mapping keys are sorted.
+func newNode(v interface{}) (*yaml.Node, error) {
+	ret := &yaml.Node{}
+	if err := ret.Encode(v); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// mergeNode returns a node representing v at path. Wherever v is unchanged
+// from the value of orig, the original nodes are reused so that key order,
+// comments and styles are preserved. Keys which are new to a mapping are
+// appended in sorted order, and merged with the node returned by original for
+// their path, so that content which has moved keeps its formatting.
+func mergeNode(orig *yaml.Node, v interface{}, path _path, original func(_path) *yaml.Node) (*yaml.Node, error) {
+	if orig == nil {
+		// Build new mappings and sequences key by key, so that any content
+		// within them which has moved is still found
+		switch v.(type) {
+		case object:
+			orig = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		case []interface{}:
+			orig = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		default:
+			return newNode(v)
+		}
+	}
+	if orig.Kind == yaml.AliasNode {
+		origValue, err := valueFromNode(orig)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(origValue, v) {
+			return orig, nil
+		}
+		return newNode(v)
+	}
+	switch val := v.(type) {
+	case object:
+		if orig.Kind != yaml.MappingNode {
+			return newNode(v)
+		}
+		ret := *orig
+		ret.Content = nil
+		seen := map[interface{}]bool{}
+		for i := 0; i+1 < len(orig.Content); i += 2 {
+			if orig.Content[i].Tag == "!!merge" {
+				continue
+			}
+			k, err := valueFromNode(orig.Content[i])
+			if err != nil {
+				return nil, err
+			}
+			item, ok := val[k]
+			if !ok {
+				continue
+			}
+			merged, err := mergeNode(orig.Content[i+1], item, path.child(fmt.Sprintf("%v", k)), original)
+			if err != nil {
+				return nil, err
+			}
+			ret.Content = append(ret.Content, orig.Content[i], merged)
+			seen[k] = true
+		}
+		for _, k := range val.sortedKeys() {
+			if seen[k] {
+				continue
+			}
+			keyNode, err := newNode(k)
+			if err != nil {
+				return nil, err
+			}
+			childPath := path.child(fmt.Sprintf("%v", k))
+			valueNode, err := mergeNode(original(childPath), val[k], childPath, original)
+			if err != nil {
+				return nil, err
+			}
+			ret.Content = append(ret.Content, keyNode, valueNode)
+		}
+		if _, ok := val["$ref"]; ok && len(val) == 1 && lookupNode(orig, _path{"$ref"}) == nil {
+			// The anchor belongs to the content which has been replaced by
+			// the reference, and moves with it
+			ret.Anchor = ""
+		}
+		blockIfNested(&ret)
+		return &ret, nil
+	case []interface{}:
+		if orig.Kind != yaml.SequenceNode {
+			return newNode(v)
+		}
+		ret := *orig
+		ret.Content = make([]*yaml.Node, len(val))
+		for i, item := range val {
+			childPath := path.child(strconv.Itoa(i))
+			origItem := original(childPath)
+			if i < len(orig.Content) {
+				origItem = orig.Content[i]
+			}
+			merged, err := mergeNode(origItem, item, childPath, original)
+			if err != nil {
+				return nil, err
+			}
+			ret.Content[i] = merged
+		}
+		blockIfNested(&ret)
+		return &ret, nil
+	}
+	if orig.Kind != yaml.ScalarNode {
+		return newNode(v)
+	}
+	origValue, err := valueFromNode(orig)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.DeepEqual(origValue, v) {
+		return orig, nil
+	}
+	return newNode(v)
+}
+
+// blockIfNested switches a flow mapping or sequence to block style if it holds
+// a block mapping or sequence, such as a new or moved schema, which cannot be
+// written within it.
+func blockIfNested(n *yaml.Node) {
+	if n.Style&yaml.FlowStyle == 0 {
+		return
+	}
+	for _, child := range n.Content {
+		if (child.Kind == yaml.MappingNode || child.Kind == yaml.SequenceNode) &&
+			child.Style&yaml.FlowStyle == 0 && len(child.Content) > 0 {
+			n.Style &^= yaml.FlowStyle
+			return
+		}
+	}
+}
+
+// blockStyle clears the style of n and every node within it, so that a
+// document read as JSON is written as plain block yaml.
+func blockStyle(n *yaml.Node) *yaml.Node {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+	return n
+}
+
+// lookupNode finds the node at path below n, or returns nil.
+func lookupNode(n *yaml.Node, path _path) *yaml.Node {
+	for n != nil && n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n == nil || len(path) == 0 {
+		return n
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil
+		}
+		return lookupNode(n.Content[0], path)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == path[0] {
+				return lookupNode(n.Content[i+1], path[1:])
+			}
+		}
+	case yaml.SequenceNode:
+		i, err := strconv.Atoi(path[0])
+		if err == nil && i >= 0 && i < len(n.Content) {
+			return lookupNode(n.Content[i], path[1:])
+		}
+	}
+	return nil
+}
+
+// copyNode returns a deep copy of n. Aliases refer to the copies of their
+// anchors, which are held in copies.
+func copyNode(n *yaml.Node, copies map[*yaml.Node]*yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	if ret, ok := copies[n]; ok {
+		return ret
+	}
+	ret := *n
+	copies[n] = &ret
+	ret.Alias = copyNode(n.Alias, copies)
+	ret.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		ret.Content[i] = copyNode(child, copies)
+	}
+	return &ret
+}
+
+// graftNode replaces the node at path below n with value, returning false if
+// there is no node at path.
+func graftNode(n *yaml.Node, path _path, value *yaml.Node) bool {
+	if len(path) == 0 {
+		return false
+	}
+	parent := lookupNode(n, path[:len(path)-1])
+	if parent == nil {
+		return false
+	}
+	key := path[len(path)-1]
+	switch parent.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(parent.Content); i += 2 {
+			if parent.Content[i].Value == key {
+				parent.Content[i+1] = value
+				return true
+			}
+		}
+	case yaml.SequenceNode:
+		i, err := strconv.Atoi(key)
+		if err == nil && i >= 0 && i < len(parent.Content) {
+			parent.Content[i] = value
+			return true
+		}
+	}
+	return false
+}
+
+// schemasIndent returns the indentation used by the entries of the
+// components.schemas mapping schemas, whose key is key, or 0 if there are
+// none. The indentation within the first schema which is a block mapping is
+// used, or else that of the entries below schemas.
+func schemasIndent(key, schemas *yaml.Node) int {
+	if !isBlockMapping(schemas) {
+		return 0
+	}
+	for j := 0; j+1 < len(schemas.Content); j += 2 {
+		if schema := schemas.Content[j+1]; isBlockMapping(schema) && schema.Line > schemas.Content[j].Line {
+			return validIndent(schema.Column - schemas.Content[j].Column)
+		}
+	}
+	return validIndent(schemas.Content[0].Column - key.Column)
+}
+
+// validIndent returns indent if the yaml encoder can write it, or else 0
+func validIndent(indent int) int {
+	if indent >= 2 && indent <= 8 {
+		return indent
+	}
+	return 0
+}
+
+// detectIndent returns the indentation used by the first indented line of a
+// yaml document, so that output can be written in the same style.
+func detectIndent(content []byte) int {
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == line || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if indent := len(line) - len(trimmed); indent >= 2 && indent <= 8 {
+			return indent
+		}
+		return 0
+	}
+	return 0
+}
+
+// writeJSONNode writes a yaml node as indented JSON, preserving key order.
+func writeJSONNode(w io.Writer, n *yaml.Node, indent string) error {
+	var buf bytes.Buffer
+	if err := appendJSONNode(&buf, n, indent); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func appendJSONNode(buf *bytes.Buffer, n *yaml.Node, indent string) error {
+	const step = "  "
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return appendJSONNode(buf, n.Content[0], indent)
+	case yaml.AliasNode:
+		return appendJSONNode(buf, n.Alias, indent)
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(indent + step)
+			if err := appendJSONValue(buf, n.Content[i].Value); err != nil {
+				return err
+			}
+			buf.WriteString(": ")
+			if err := appendJSONNode(buf, n.Content[i+1], indent+step); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "}")
+		return nil
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, item := range n.Content {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(indent + step)
+			if err := appendJSONNode(buf, item, indent+step); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "]")
+		return nil
+	}
+	// Numbers are written as they were, e.g. 1.0 rather than 1
+	if (n.Tag == "!!int" || n.Tag == "!!float") && json.Valid([]byte(n.Value)) {
+		buf.WriteString(n.Value)
+		return nil
+	}
+	v, err := valueFromNode(n)
+	if err != nil {
+		return err
+	}
+	return appendJSONValue(buf, v)
+}
+
+func appendJSONValue(buf *bytes.Buffer, v interface{}) error {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(encoded.Bytes(), []byte("\n")))
+	return nil
+}
@@ -2,13 +2,17 @@ package spec
 
 import (
 	"fmt"
-	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type object map[interface{}]interface{}
 
+// filterExp matches a filter expression such as [?(@type=='object')]
+var filterExp = regexp.MustCompile(`^\[\?\(@([[:alnum:]]+)=='([[:alnum:]]+)'\)\]`)
+
 func (o object) findPath(findPath _path, parentPath _path) []objectWithPath {
 	if len(findPath) == 0 {
 		return []objectWithPath{{object: o, path: parentPath}}
@@ -16,35 +20,34 @@ func (o object) findPath(findPath _path, parentPath _path) []objectWithPath {
 	// from arbitrary depth '..'
 	if findPath[0] == "" {
 		ret := o.findPath(findPath[1:], parentPath)
-		for k, v := range o {
-			obj, ok := v.(object)
-			if ok {
-				key := fmt.Sprintf("%v", k)
-				ret = append(ret, obj.findPath(findPath, append(parentPath, key))...)
-			}
+		for _, k := range o.sortedKeys() {
+			ret = append(ret, findPathIn(o[k], findPath, parentPath.child(fmt.Sprintf("%v", k)))...)
 		}
 		return ret
 	}
 	if findPath[0] == "*" {
 		ret := []objectWithPath{}
-		for k, v := range o {
-			obj, ok := v.(object)
-			if ok {
-				key := fmt.Sprintf("%v", k)
-				ret = append(ret, obj.findPath(findPath[1:], append(parentPath, key))...)
-			}
+		for _, k := range o.sortedKeys() {
+			ret = append(ret, findPathIn(o[k], findPath[1:], parentPath.child(fmt.Sprintf("%v", k)))...)
 		}
 		return ret
 	}
 
-	exp := regexp.MustCompile(`^\[\?\(@([[:alnum:]]+)=='([[:alnum:]]+)'\)\]`)
-	result := exp.FindStringSubmatch(findPath[0])
+	result := filterExp.FindStringSubmatch(findPath[0])
 	if result != nil {
 		if o[result[1]] == result[2] {
 			return []objectWithPath{{object: o, path: parentPath}}
 		}
 		return nil
 	}
+	// union of keys '[a,b]'
+	if strings.HasPrefix(findPath[0], "[") && strings.HasSuffix(findPath[0], "]") {
+		ret := []objectWithPath{}
+		for _, key := range strings.Split(strings.Trim(findPath[0], "[]"), ",") {
+			ret = append(ret, o.findPath(append(_path{key}, findPath[1:]...), parentPath)...)
+		}
+		return ret
+	}
 	v, ok := o[findPath[0]]
 	if !ok {
 		// try again with int
@@ -55,62 +58,135 @@ func (o object) findPath(findPath _path, parentPath _path) []objectWithPath {
 		v, ok = o[i]
 	}
 	if ok {
-		obj, ok := v.(object)
-		if ok {
-			return obj.findPath(findPath[1:], append(parentPath, findPath[0]))
-		}
+		return findPathIn(v, findPath[1:], parentPath.child(findPath[0]))
+	}
+	return nil
+}
+
+// findPathIn continues a search into any value, descending into both objects
+// and sequences (e.g. allOf/oneOf/anyOf), where the index is used as the key.
+func findPathIn(v interface{}, findPath _path, parentPath _path) []objectWithPath {
+	switch val := v.(type) {
+	case object:
+		return val.findPath(findPath, parentPath)
+	case []interface{}:
+		return findPathInSequence(val, findPath, parentPath)
 	}
 	return nil
 }
 
-func (o object) getOrCreateChildObject(name string) object {
+func findPathInSequence(seq []interface{}, findPath _path, parentPath _path) []objectWithPath {
+	if len(findPath) == 0 {
+		return nil
+	}
+	switch findPath[0] {
+	case "":
+		ret := findPathInSequence(seq, findPath[1:], parentPath)
+		for i, v := range seq {
+			ret = append(ret, findPathIn(v, findPath, parentPath.child(strconv.Itoa(i)))...)
+		}
+		return ret
+	case "*":
+		ret := []objectWithPath{}
+		for i, v := range seq {
+			ret = append(ret, findPathIn(v, findPath[1:], parentPath.child(strconv.Itoa(i)))...)
+		}
+		return ret
+	}
+	i, err := strconv.Atoi(findPath[0])
+	if err != nil || i < 0 || i >= len(seq) {
+		return nil
+	}
+	return findPathIn(seq[i], findPath[1:], parentPath.child(findPath[0]))
+}
+
+// sortedKeys returns the keys of o in a stable order, so that traversal does
+// not depend on map iteration order.
+func (o object) sortedKeys() []interface{} {
+	type sortKey struct {
+		key      interface{}
+		str, typ string
+	}
+	sortKeys := make([]sortKey, 0, len(o))
+	for k := range o {
+		sortKeys = append(sortKeys, sortKey{key: k, str: fmt.Sprintf("%v", k), typ: fmt.Sprintf("%T", k)})
+	}
+	sort.Slice(sortKeys, func(i, j int) bool {
+		if sortKeys[i].str != sortKeys[j].str {
+			return sortKeys[i].str < sortKeys[j].str
+		}
+		return sortKeys[i].typ < sortKeys[j].typ
+	})
+	keys := make([]interface{}, len(sortKeys))
+	for i, k := range sortKeys {
+		keys[i] = k.key
+	}
+	return keys
+}
+
+// objectsOnPath returns each object from o to the value at path, inclusive.
+func (o object) objectsOnPath(path _path) []object {
+	ret := []object{o}
+	var v interface{} = o
+	for _, key := range path {
+		switch val := v.(type) {
+		case object:
+			child, ok := val[key]
+			if !ok {
+				if i, err := strconv.Atoi(key); err == nil {
+					child = val[i]
+				}
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(val) {
+				return ret
+			}
+			v = val[i]
+		default:
+			return ret
+		}
+		if obj, ok := v.(object); ok {
+			ret = append(ret, obj)
+		}
+	}
+	return ret
+}
+
+func (o object) getOrCreateChildObject(name string) (object, error) {
 	r, ok := o[name]
 	if !ok {
 		ret := object{}
 		o[name] = ret
-		return ret
+		return ret, nil
 	}
 
 	ret, ok := r.(object)
 	if !ok {
-		panic(fmt.Errorf("%s is not object", name))
+		return nil, fmt.Errorf("%s is not object", name)
 	}
-	return ret
-}
-
-func (o object) isEqual(other object) bool {
-	return reflect.DeepEqual(o, other)
-	// for k, v := range o {
-	// 	if k == "description" {
-	// 		continue
-	// 	}
-	// 	childObj, ok := v.(Object)
-	// 	if ok {
-	// 		childOtherObj, ok := other[k].(Object)
-	// 		if !ok {
-	// 			return false
-	// 		}
-	// 		if !childObj.isEqual(childOtherObj) {
-	// 			return false
-	// 		}
-	// 	}else{
-	// 		if reflect.DeepEqual(v, other[k]) {
-	// 			continue
-	// 		}
-	// 	}
-	// }
-	// return true
+	return ret, nil
 }
 
 func copyObject(m object) object {
 	cp := make(object)
 	for k, v := range m {
-		vm, ok := v.(object)
-		if ok {
-			cp[k] = copyObject(vm)
-		} else {
-			cp[k] = v
-		}
+		cp[k] = copyValue(v)
 	}
 	return cp
 }
+
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case object:
+		return copyObject(val)
+	case []interface{}:
+		cp := make([]interface{}, len(val))
+		for i, item := range val {
+			cp[i] = copyValue(item)
+		}
+		return cp
+	}
+	return v
+}
@@ -0,0 +1,130 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+)
+
+// Location identifies one of the places in a document where inline schemas
+// are searched for.
+type Location string
+
+const (
+	LocationRequestBody          Location = "requestBody"
+	LocationResponseBody         Location = "responseBody"
+	LocationParameter            Location = "parameter"
+	LocationPathParameter        Location = "pathParameter"
+	LocationResponseHeader       Location = "responseHeader"
+	LocationCallbackRequestBody  Location = "callbackRequestBody"
+	LocationCallbackResponseBody Location = "callbackResponseBody"
+	LocationCallbackParameter    Location = "callbackParameter"
+	LocationComponentParameter   Location = "componentParameter"
+	LocationComponentRequestBody Location = "componentRequestBody"
+	LocationComponentResponse    Location = "componentResponse"
+	LocationComponentHeader      Location = "componentHeader"
+	LocationEmbeddedObject       Location = "embeddedObject"
+	LocationEmbeddedArrayObject  Location = "embeddedArrayObject"
+	LocationComposition          Location = "composition"
+)
+
+// Options controls the behaviour of Transform. The zero value searches every
+// location and logs nothing.
+type Options struct {
+	// Locations limits the search to the given locations. Every location is
+	// searched if empty. Transform returns an error for an unknown location.
+	Locations []Location
+	// Log receives progress messages, if set.
+	Log io.Writer
+	// UseOperationID names request and response bodies by the operationId
+	// of their operation, e.g. CreatePetRequest, falling back to the default
+	// rules where a schema is shared by operations with different (or no)
+	// operationIds.
+	UseOperationID bool
+	// NameHintKeys are the keys of an inline schema which give the name it
+	// should be extracted as, in order of preference. Hints which are not
+	// valid identifiers are ignored. If nil, x-schema-name, x-go-name and
+	// title are used; if empty, hints are ignored.
+	NameHintKeys []string
+	// InlineArrays leaves request and response bodies which are arrays
+	// inline, extracting only their items as {Name}Item. The items of arrays
+	// in parameters are always extracted this way.
+	InlineArrays bool
+	// CollisionResolver chooses a new name when a schema would be given a name
+	// which already exists. ContextResolver is used if nil.
+	CollisionResolver CollisionResolver
+	// Comparator decides whether schemas are the same, so that they are
+	// extracted as one or reuse an existing schema.
+	Comparator Comparator
+	// Naming gives a text/template for the names of schemas found at a
+	// location, executed with NameData. The default naming rules are used
+	// for any location without a template.
+	Naming map[Location]string
+	// Policies limit which schemas are extracted at each location. Every
+	// schema found is extracted at a location without a policy.
+	Policies map[Location]Policy
+}
+
+// checkLocations returns an error if any of the locations to search is
+// unknown, since it would never match
+func checkLocations(locations []Location) error {
+	for _, location := range locations {
+		if !isLocation(location) {
+			return fmt.Errorf("search of unknown location %q", location)
+		}
+	}
+	return nil
+}
+
+func (o Options) searches(location Location) bool {
+	if len(o.Locations) == 0 {
+		return true
+	}
+	for _, l := range o.Locations {
+		if l == location {
+			return true
+		}
+	}
+	return false
+}
+
+// Reason describes why the name of an extracted schema was chosen.
+type Reason string
+
+const (
+	// ReasonUnique is given when the name identifies a single use, or uses
+	// which differ only in content type or status code
+	ReasonUnique Reason = "unique"
+	// ReasonCommonByVerb is given when the schema is shared by operations with
+	// the same verb on different paths
+	ReasonCommonByVerb Reason = "common-by-verb"
+	// ReasonCommonByPath is given when the schema is shared by operations on
+	// the same path with different verbs
+	ReasonCommonByPath Reason = "common-by-path"
+	// ReasonCommon is given for any other schema with more than one use
+	ReasonCommon Reason = "common"
+	// ReasonHint is given when the name was given by a key of the schema,
+	// such as title or x-schema-name
+	ReasonHint Reason = "hint"
+	// ReasonReused is given when an identical schema already existed in
+	// components.schemas
+	ReasonReused Reason = "reused"
+)
+
+// Extraction describes a schema which has been moved to components.schemas.
+type Extraction struct {
+	// Schema is the name of the schema in components.schemas
+	Schema string `json:"schema"`
+	// Location is the kind of location the schema was found in
+	Location Location `json:"location"`
+	// Pointers are JSON pointers to each location in the input document which
+	// now references Schema
+	Pointers []string `json:"pointers"`
+	// Reason describes why the name was chosen
+	Reason Reason `json:"reason"`
+	// Candidate is the name which would have been chosen, if it differs from
+	// Schema because that name was already taken
+	Candidate string `json:"candidate,omitempty"`
+	// Reused is set if an identical schema already existed, so that no new
+	// schema was added
+	Reused bool `json:"reused,omitempty"`
+}
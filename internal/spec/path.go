@@ -2,6 +2,7 @@ package spec
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -14,94 +15,416 @@ func newPath(stringPath string) _path {
 	return strings.Split(strings.TrimPrefix(stringPath, "."), ".")
 }
 
-func (ps paths) responseSymbol() (string, error) {
+// child returns a copy of the path extended by key, so that sibling paths
+// never share a backing array.
+func (p _path) child(key string) _path {
+	ret := make(_path, len(p), len(p)+1)
+	copy(ret, p)
+	return append(ret, key)
+}
+
+// pointer returns the path as a JSON pointer, e.g. /paths/~1v2~1foo/post
+func (p _path) pointer() string {
+	var sb strings.Builder
+	for _, key := range p {
+		sb.WriteString("/")
+		sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1"))
+	}
+	return sb.String()
+}
+
+func (ps paths) pointers() []string {
+	ret := make([]string, len(ps))
+	for i, p := range ps {
+		ret[i] = p.pointer()
+	}
+	return ret
+}
+
+func (ps paths) responseSymbol() (string, Reason, error) {
 	if len(ps) == 0 {
-		return "", fmt.Errorf("no paths found")
+		return "", "", fmt.Errorf("no paths found")
 	}
 	parts := []string{}
+	reason := ReasonUnique
 	verb, err := ps.commonValueAtIndex(2)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	if verb != "" {
 		parts = append(parts, toTitle(verb))
 	}
 	endpoint, err := ps.commonValueAtIndex(1)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	if endpoint != "" {
 		parts = append(parts, sanitizeURLPath(endpoint))
 	}
 	statusCode, err := ps.commonStatusCode()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	if statusCode != "" {
-		parts = append(parts, statusCode)
+		parts = append(parts, sanitizeURLPath(statusCode))
 	}
 
 	if len(parts) != 3 {
 		parts = append([]string{"Common"}, parts...)
+		reason = sharedReason(verb, endpoint)
 	}
 	parts = append(parts, "Response")
 
-	return strings.Join(parts, ""), nil
+	return strings.Join(parts, ""), reason, nil
 }
 
-func (ps paths) embeddedSymbol() (string, error) {
+// subschemaSymbol names an inline schema found within a schema in
+// components.schemas by the keywords leading to it. The variant of a
+// composition is named by the discriminator value when one is given,
+// otherwise by its (1-based) position in the array.
+func (ps paths) subschemaSymbol(discriminatorValue string) (string, Reason, error) {
 	if len(ps) == 0 {
-		return "", fmt.Errorf("no paths found")
+		return "", "", fmt.Errorf("no paths found")
 	}
 	path := ps[0]
-	if len(path) < 5 {
-		return "", fmt.Errorf("path too short")
+	name, err := subschemaName(path, discriminatorValue)
+	if err != nil {
+		return "", "", err
 	}
-	if len(ps) == 1 {
-		return path[len(path)-3] + capitalizeFirst(path[len(path)-1]), nil
+	if len(ps) > 1 {
+		return "Common" + name, ReasonCommon, nil
 	}
-	return "Common" + capitalizeFirst(path[len(path)-1]), nil
+	return path[2] + name, ReasonUnique, nil
 }
 
-func (ps paths) embeddedArraySymbol() (string, error) {
-	if len(ps) == 0 {
-		return "", fmt.Errorf("no paths found")
+// compositionVariant names the branch of a composition at path
+func compositionVariant(path _path, discriminatorValue string) (string, error) {
+	if discriminatorValue != "" {
+		return sanitizeURLPath(discriminatorValue), nil
 	}
-	path := ps[0]
-	if len(path) < 6 {
-		return "", fmt.Errorf("path too short")
+	index, err := strconv.Atoi(path[len(path)-1])
+	if err != nil {
+		return "", err
 	}
-	if len(ps) == 1 {
-		return path[len(path)-4] + capitalizeFirst(path[len(path)-2]) + "Item", nil
+	variant := "Variant"
+	if path[len(path)-2] == "allOf" {
+		variant = "Part"
 	}
-	return "Common" + capitalizeFirst(path[len(path)-2]) + "Item", nil
+	return variant + strconv.Itoa(index+1), nil
 }
 
-func (ps paths) requestSymbol() (string, error) {
+// operationIDSymbol names a request or response body by the operationId of
+// its operation, given the operationId of each use. It returns "" unless every
+// use has the same operationId, so that shared schemas are named by the
+// default rules.
+func (ps paths) operationIDSymbol(operationIDs []string, suffix string) string {
+	id := commonValue(operationIDs)
+	if id == "" {
+		return ""
+	}
+	name := sanitizeURLPath(id)
+	if suffix == "Response" {
+		statusCode, err := ps.commonStatusCode()
+		if err != nil {
+			return ""
+		}
+		name += sanitizeURLPath(statusCode)
+	}
+	return name + suffix
+}
+
+func (ps paths) requestSymbol() (string, Reason, error) {
 	if len(ps) == 0 {
-		return "", fmt.Errorf("no paths found")
+		return "", "", fmt.Errorf("no paths found")
 	}
 	parts := []string{}
+	reason := ReasonUnique
 	verb, err := ps.commonValueAtIndex(2)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	if verb != "" {
 		parts = append(parts, toTitle(verb))
 	}
 	endpoint, err := ps.commonValueAtIndex(1)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	if endpoint != "" {
 		parts = append(parts, sanitizeURLPath(endpoint))
 	}
 	if len(parts) != 2 {
 		parts = append([]string{"Common"}, parts...)
+		reason = sharedReason(verb, endpoint)
 	}
 	parts = append(parts, "Request")
 
-	return strings.Join(parts, ""), nil
+	return strings.Join(parts, ""), reason, nil
+}
+
+func (ps paths) parameterSymbol(names []string) (string, Reason, error) {
+	if len(ps) == 0 {
+		return "", "", fmt.Errorf("no paths found")
+	}
+	parts := []string{}
+	reason := ReasonUnique
+	verb, err := ps.commonValueAtIndex(2)
+	if err != nil {
+		return "", "", err
+	}
+	if verb != "" {
+		parts = append(parts, toTitle(verb))
+	}
+	endpoint, err := ps.commonValueAtIndex(1)
+	if err != nil {
+		return "", "", err
+	}
+	if endpoint != "" {
+		parts = append(parts, sanitizeURLPath(endpoint))
+	}
+	if name := commonValue(names); name != "" {
+		parts = append(parts, sanitizeURLPath(name))
+	}
+	if len(parts) != 3 && len(ps) > 1 {
+		parts = append([]string{"Common"}, parts...)
+		reason = sharedReason(verb, endpoint)
+	}
+	parts = append(parts, "Param")
+
+	return strings.Join(parts, ""), reason, nil
+}
+
+// pathParameterSymbol names a parameter defined for all operations of a path
+func (ps paths) pathParameterSymbol(names []string) (string, Reason, error) {
+	if len(ps) == 0 {
+		return "", "", fmt.Errorf("no paths found")
+	}
+	parts := []string{}
+	reason := ReasonUnique
+	endpoint, err := ps.commonValueAtIndex(1)
+	if err != nil {
+		return "", "", err
+	}
+	if endpoint != "" {
+		parts = append(parts, sanitizeURLPath(endpoint))
+	}
+	if name := commonValue(names); name != "" {
+		parts = append(parts, sanitizeURLPath(name))
+	}
+	if len(parts) != 2 && len(ps) > 1 {
+		parts = append([]string{"Common"}, parts...)
+		reason = sharedReason("", endpoint)
+	}
+	parts = append(parts, "Param")
+
+	return strings.Join(parts, ""), reason, nil
+}
+
+func (ps paths) headerSymbol() (string, Reason, error) {
+	if len(ps) == 0 {
+		return "", "", fmt.Errorf("no paths found")
+	}
+	parts := []string{}
+	reason := ReasonUnique
+	verb, err := ps.commonValueAtIndex(2)
+	if err != nil {
+		return "", "", err
+	}
+	if verb != "" {
+		parts = append(parts, toTitle(verb))
+	}
+	endpoint, err := ps.commonValueAtIndex(1)
+	if err != nil {
+		return "", "", err
+	}
+	if endpoint != "" {
+		parts = append(parts, sanitizeURLPath(endpoint))
+	}
+	statusCode, err := ps.commonStatusCode()
+	if err != nil {
+		return "", "", err
+	}
+	if statusCode != "" {
+		parts = append(parts, sanitizeURLPath(statusCode))
+	}
+	header, err := ps.commonValueAtIndex(6)
+	if err != nil {
+		return "", "", err
+	}
+	if header != "" {
+		parts = append(parts, sanitizeURLPath(header))
+	}
+	if len(parts) != 4 {
+		parts = append([]string{"Common"}, parts...)
+		reason = sharedReason(verb, endpoint)
+	}
+	parts = append(parts, "Header")
+
+	return strings.Join(parts, ""), reason, nil
+}
+
+// callbackSymbol names a request or response body of a callback, either
+// within an operation or under components.callbacks.
+func (ps paths) callbackSymbol(suffix string) (string, Reason, error) {
+	if len(ps) == 0 {
+		return "", "", fmt.Errorf("no paths found")
+	}
+	var names, verbs, statusCodes []string
+	for _, path := range ps {
+		idx := 4
+		if path[0] == "components" {
+			idx = 2
+		}
+		if len(path) <= idx+2 {
+			return "", "", fmt.Errorf("path too short")
+		}
+		names = append(names, path[idx])
+		verbs = append(verbs, path[idx+2])
+		if suffix == "Response" {
+			if len(path) <= idx+4 {
+				return "", "", fmt.Errorf("path too short")
+			}
+			statusCodes = append(statusCodes, path[idx+4])
+		}
+	}
+	parts := []string{}
+	reason := ReasonUnique
+	expected := 2
+	if name := commonValue(names); name != "" {
+		parts = append(parts, sanitizeURLPath(name))
+	}
+	verb := commonValue(verbs)
+	if verb != "" {
+		parts = append(parts, toTitle(verb))
+	}
+	if suffix == "Response" {
+		expected++
+		if statusCode := commonStatusCode(statusCodes); statusCode != "" {
+			parts = append(parts, sanitizeURLPath(statusCode))
+		}
+	}
+	if len(parts) != expected {
+		parts = append([]string{"Common"}, parts...)
+		reason = sharedReason(verb, "")
+	}
+	parts = append(parts, suffix)
+
+	return strings.Join(parts, ""), reason, nil
+}
+
+// callbackParameterSymbol names a parameter of a callback operation, either
+// within an operation or under components.callbacks.
+func (ps paths) callbackParameterSymbol(names []string) (string, Reason, error) {
+	if len(ps) == 0 {
+		return "", "", fmt.Errorf("no paths found")
+	}
+	var callbacks, verbs []string
+	for _, path := range ps {
+		idx := 4
+		if path[0] == "components" {
+			idx = 2
+		}
+		if len(path) <= idx+2 {
+			return "", "", fmt.Errorf("path too short")
+		}
+		callbacks = append(callbacks, path[idx])
+		verbs = append(verbs, path[idx+2])
+	}
+	parts := []string{}
+	reason := ReasonUnique
+	if callback := commonValue(callbacks); callback != "" {
+		parts = append(parts, sanitizeURLPath(callback))
+	}
+	verb := commonValue(verbs)
+	if verb != "" {
+		parts = append(parts, toTitle(verb))
+	}
+	if name := commonValue(names); name != "" {
+		parts = append(parts, sanitizeURLPath(name))
+	}
+	if len(parts) != 3 && len(ps) > 1 {
+		parts = append([]string{"Common"}, parts...)
+		reason = sharedReason(verb, "")
+	}
+	parts = append(parts, "Param")
+
+	return strings.Join(parts, ""), reason, nil
+}
+
+// componentSymbol names a schema found within a named component such as
+// components.parameters.{name}.
+func (ps paths) componentSymbol(suffix string) (string, Reason, error) {
+	if len(ps) == 0 {
+		return "", "", fmt.Errorf("no paths found")
+	}
+	path := ps[0]
+	if len(path) < 3 {
+		return "", "", fmt.Errorf("path too short")
+	}
+	name := sanitizeURLPath(path[2]) + suffix
+	if len(ps) > 1 {
+		return "Common" + name, ReasonCommon, nil
+	}
+	return name, ReasonUnique, nil
+}
+
+// componentHeaderSymbol names a schema found in components.headers or in the
+// headers of a response in components.responses.
+func (ps paths) componentHeaderSymbol() (string, Reason, error) {
+	if len(ps) == 0 {
+		return "", "", fmt.Errorf("no paths found")
+	}
+	path := ps[0]
+	name := componentHeaderName(path)
+	if name == "" {
+		return "", "", fmt.Errorf("path too short")
+	}
+	header := sanitizeURLPath(name) + "Header"
+	if len(ps) > 1 {
+		return "Common" + header, ReasonCommon, nil
+	}
+	if path[1] == "responses" {
+		return sanitizeURLPath(path[2]) + header, ReasonUnique, nil
+	}
+	return header, ReasonUnique, nil
+}
+
+// componentHeaderName returns the name of the header in components.headers, or
+// in the headers of a response in components.responses, at path
+func componentHeaderName(path _path) string {
+	idx := 2
+	if len(path) > 1 && path[1] == "responses" {
+		idx = 4
+	}
+	if len(path) <= idx {
+		return ""
+	}
+	return path[idx]
+}
+
+// sharedReason describes why a shared name was chosen, given the verb and
+// endpoint common to all uses, if any
+func sharedReason(verb, endpoint string) Reason {
+	switch {
+	case endpoint != "" && verb == "":
+		return ReasonCommonByPath
+	case verb != "" && endpoint == "":
+		return ReasonCommonByVerb
+	}
+	return ReasonCommon
+}
+
+// commonValue returns the value if all values are the same, otherwise ""
+func commonValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	for _, v := range values {
+		if v != values[0] {
+			return ""
+		}
+	}
+	return values[0]
 }
 
 func (ps paths) commonValueAtIndex(idx int) (string, error) {
@@ -130,22 +453,34 @@ func (ps paths) commonStatusCode() (string, error) {
 	if len(ps) == 0 {
 		return "", fmt.Errorf("no paths found")
 	}
-	var ret string
+	statusCodes := make([]string, len(ps))
 	for i, path := range ps {
 		if len(path) <= idx {
 			return "", fmt.Errorf("path too short")
 		}
-		newVal := path[idx]
+		if path[idx] == "" {
+			return "", fmt.Errorf("empty status code")
+		}
+		statusCodes[i] = path[idx]
+	}
+	return commonStatusCode(statusCodes), nil
+}
+
+// commonStatusCode returns the status code if all are the same, {prefix}xx if
+// they share a prefix, otherwise ""
+func commonStatusCode(statusCodes []string) string {
+	var ret string
+	for i, newVal := range statusCodes {
 		if i == 0 {
 			ret = newVal
 		} else {
 			if ret != newVal {
-				ret = ret[:1] + "xx"
-				if ret[:1] != newVal[:1] {
-					return "", nil
+				if ret == "" || newVal == "" || ret[:1] != newVal[:1] {
+					return ""
 				}
+				ret = ret[:1] + "xx"
 			}
 		}
 	}
-	return ret, nil
+	return ret
 }
@@ -8,41 +8,54 @@ import (
 
 func TestPaths_requestSymbol(t *testing.T) {
 	tests := map[string]struct {
-		paths paths
-		want  string
+		paths      paths
+		want       string
+		wantReason Reason
 	}{
 		"single request body": {
 			paths: []_path{
 				{"paths", "/v2/foo", "POST", "requestBody", "content", "application/json", "schema"},
 			},
-			want: "PostV2FooRequest",
+			want:       "PostV2FooRequest",
+			wantReason: ReasonUnique,
 		},
 		"single request with dash in endpoint": {
 			paths: []_path{
 				{"paths", "/v2/mandate-imports", "POST", "requestBody", "content", "application/json", "schema"},
 			},
-			want: "PostV2MandateImportsRequest",
+			want:       "PostV2MandateImportsRequest",
+			wantReason: ReasonUnique,
+		},
+		"single request with path parameter and extension": {
+			paths: []_path{
+				{"paths", "/v2/users/{userId}/items.json", "POST", "requestBody", "content", "application/json", "schema"},
+			},
+			want:       "PostV2UsersByUserIdItemsJsonRequest",
+			wantReason: ReasonUnique,
 		},
 		"common endpoint": {
 			paths: []_path{
 				{"paths", "/v2/foo", "POST", "requestBody", "content", "application/json", "schema"},
 				{"paths", "/v2/foo", "GET", "requestBody", "content", "application/json", "schema"},
 			},
-			want: "CommonV2FooRequest",
+			want:       "CommonV2FooRequest",
+			wantReason: ReasonCommonByPath,
 		},
 		"common verb": {
 			paths: []_path{
 				{"paths", "/v2/foo", "POST", "requestBody", "content", "application/json", "schema"},
 				{"paths", "/v2/ping", "POST", "requestBody", "content", "application/json", "schema"},
 			},
-			want: "CommonPostRequest",
+			want:       "CommonPostRequest",
+			wantReason: ReasonCommonByVerb,
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := tt.paths.requestSymbol()
+			got, reason, err := tt.paths.requestSymbol()
 			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantReason, reason)
 		})
 	}
 }
@@ -85,68 +98,478 @@ func TestPaths_responseSymbol(t *testing.T) {
 			},
 			want: "PostV2Ping2xxResponse",
 		},
+		"default response": {
+			paths: []_path{
+				{"paths", "/v2/users/{userId}/items.json", "POST", "responses", "default"},
+			},
+			want: "PostV2UsersByUserIdItemsJsonDefaultResponse",
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := tt.paths.responseSymbol()
+			got, _, err := tt.paths.responseSymbol()
 			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
 
-func TestPaths_embeddedSymbol(t *testing.T) {
+func TestPaths_subschemaSymbol(t *testing.T) {
 	tests := map[string]struct {
-		paths paths
-		want  string
+		paths         paths
+		discriminator string
+		want          string
 	}{
-		"single response": {
+		"property": {
 			paths: []_path{
 				{"components", "schemas", "fooBar", "properties", "whizzBang"},
 			},
 			want: "fooBarWhizzBang",
 		},
-		"multiple response": {
+		"shared property": {
 			paths: []_path{
 				{"components", "schemas", "fooBar", "properties", "whizzBang"},
 				{"components", "schemas", "pingPong", "properties", "whizzBang"},
 			},
 			want: "CommonWhizzBang",
 		},
+		"array": {
+			paths: []_path{
+				{"components", "schemas", "fooBar", "properties", "whizzBang", "items"},
+			},
+			want: "fooBarWhizzBangItem",
+		},
+		"shared array": {
+			paths: []_path{
+				{"components", "schemas", "fooBar", "properties", "whizzBang", "items"},
+				{"components", "schemas", "pingPong", "properties", "whizzBang", "items"},
+			},
+			want: "CommonWhizzBangItem",
+		},
+		"nested array": {
+			paths: []_path{
+				{"components", "schemas", "Grid", "properties", "rows", "items", "items"},
+			},
+			want: "GridRowsItemItem",
+		},
+		"tuple": {
+			paths: []_path{
+				{"components", "schemas", "Pair", "prefixItems", "1"},
+			},
+			want: "PairItem2",
+		},
+		"additionalProperties": {
+			paths: []_path{
+				{"components", "schemas", "Pet", "properties", "tags", "additionalProperties"},
+			},
+			want: "PetTagsValue",
+		},
+		"patternProperties": {
+			paths: []_path{
+				{"components", "schemas", "Pet", "patternProperties", "^x-"},
+			},
+			want: "PetXValue",
+		},
+		"not": {
+			paths: []_path{
+				{"components", "schemas", "Pet", "not"},
+			},
+			want: "PetNot",
+		},
+		"oneOf": {
+			paths: []_path{
+				{"components", "schemas", "Pet", "oneOf", "1"},
+			},
+			want: "PetVariant2",
+		},
+		"allOf": {
+			paths: []_path{
+				{"components", "schemas", "Pet", "allOf", "0"},
+			},
+			want: "PetPart1",
+		},
+		"discriminator": {
+			paths: []_path{
+				{"components", "schemas", "Pet", "oneOf", "0"},
+			},
+			discriminator: "cat",
+			want:          "PetCat",
+		},
+		"composition within property": {
+			paths: []_path{
+				{"components", "schemas", "Owner", "properties", "pet", "anyOf", "0"},
+			},
+			want: "OwnerPetVariant1",
+		},
+		"nested composition": {
+			paths: []_path{
+				{"components", "schemas", "Pet", "allOf", "1", "oneOf", "0"},
+			},
+			discriminator: "cat",
+			want:          "PetPart2Cat",
+		},
+		"shared composition": {
+			paths: []_path{
+				{"components", "schemas", "Pet", "oneOf", "0"},
+				{"components", "schemas", "Animal", "oneOf", "0"},
+			},
+			want: "CommonVariant1",
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := tt.paths.embeddedSymbol()
+			got, _, err := tt.paths.subschemaSymbol(tt.discriminator)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
 
-func TestPaths_embeddedArraySymbol(t *testing.T) {
+func TestPaths_parameterSymbol(t *testing.T) {
 	tests := map[string]struct {
 		paths paths
+		names []string
 		want  string
 	}{
-		"single response": {
+		"single parameter": {
 			paths: []_path{
-				{"components", "schemas", "fooBar", "properties", "whizzBang", "items"},
+				{"paths", "/v2/foo", "get", "parameters", "0", "schema"},
 			},
-			want: "fooBarWhizzBangItem",
+			names: []string{"filter"},
+			want:  "GetV2FooFilterParam",
 		},
-		"multiple response": {
+		"common name": {
 			paths: []_path{
-				{"components", "schemas", "fooBar", "properties", "whizzBang", "items"},
-				{"components", "schemas", "pingPong", "properties", "whizzBang", "items"},
+				{"paths", "/v2/foo", "get", "parameters", "0", "schema"},
+				{"paths", "/v2/ping", "get", "parameters", "1", "schema"},
 			},
-			want: "CommonWhizzBangItem",
+			names: []string{"filter", "filter"},
+			want:  "CommonGetFilterParam",
+		},
+		"single parameter without a name": {
+			paths: []_path{
+				{"paths", "/v2/foo", "get", "parameters", "0", "schema"},
+			},
+			names: []string{""},
+			want:  "GetV2FooParam",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := tt.paths.parameterSymbol(tt.names)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPaths_headerSymbol(t *testing.T) {
+	tests := map[string]struct {
+		paths paths
+		want  string
+	}{
+		"single header": {
+			paths: []_path{
+				{"paths", "/v2/foo", "get", "responses", "200", "headers", "X-Rate-Limit", "schema"},
+			},
+			want: "GetV2Foo200XRateLimitHeader",
+		},
+		"common header": {
+			paths: []_path{
+				{"paths", "/v2/foo", "get", "responses", "200", "headers", "X-Rate-Limit", "schema"},
+				{"paths", "/v2/foo", "get", "responses", "201", "headers", "X-Rate-Limit", "schema"},
+			},
+			want: "GetV2Foo2xxXRateLimitHeader",
+		},
+		"different headers": {
+			paths: []_path{
+				{"paths", "/v2/foo", "get", "responses", "200", "headers", "X-Rate-Limit", "schema"},
+				{"paths", "/v2/ping", "get", "responses", "200", "headers", "X-Other", "schema"},
+			},
+			want: "CommonGet200Header",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := tt.paths.headerSymbol()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPaths_callbackSymbol(t *testing.T) {
+	tests := map[string]struct {
+		paths  paths
+		suffix string
+		want   string
+	}{
+		"operation callback request": {
+			paths: []_path{
+				{"paths", "/v2/foo", "post", "callbacks", "onEvent", "{$request.body#/url}", "post", "requestBody", "content", "application/json", "schema"},
+			},
+			suffix: "Request",
+			want:   "OnEventPostRequest",
+		},
+		"component callback response": {
+			paths: []_path{
+				{"components", "callbacks", "onEvent", "{$request.body#/url}", "post", "responses", "200", "content", "application/json", "schema"},
+			},
+			suffix: "Response",
+			want:   "OnEventPost200Response",
+		},
+		"common verb": {
+			paths: []_path{
+				{"components", "callbacks", "onEvent", "{$request.body#/url}", "post", "requestBody", "content", "application/json", "schema"},
+				{"paths", "/v2/foo", "post", "callbacks", "onOther", "{$request.body#/url}", "post", "requestBody", "content", "application/json", "schema"},
+			},
+			suffix: "Request",
+			want:   "CommonPostRequest",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := tt.paths.callbackSymbol(tt.suffix)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPaths_callbackParameterSymbol(t *testing.T) {
+	tests := map[string]struct {
+		paths paths
+		names []string
+		want  string
+	}{
+		"operation callback": {
+			paths: []_path{
+				{"paths", "/v2/foo", "post", "callbacks", "onEvent", "{$request.body#/url}", "post", "parameters", "0", "schema"},
+			},
+			names: []string{"filter"},
+			want:  "OnEventPostFilterParam",
+		},
+		"common name": {
+			paths: []_path{
+				{"components", "callbacks", "onEvent", "{$request.body#/url}", "post", "parameters", "0", "schema"},
+				{"paths", "/v2/foo", "post", "callbacks", "onOther", "{$request.body#/url}", "post", "parameters", "0", "schema"},
+			},
+			names: []string{"filter", "filter"},
+			want:  "CommonPostFilterParam",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := tt.paths.callbackParameterSymbol(tt.names)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPaths_componentSymbol(t *testing.T) {
+	tests := map[string]struct {
+		paths  paths
+		suffix string
+		want   string
+	}{
+		"parameter": {
+			paths: []_path{
+				{"components", "parameters", "filter", "schema"},
+			},
+			suffix: "Param",
+			want:   "FilterParam",
+		},
+		"request body": {
+			paths: []_path{
+				{"components", "requestBodies", "NewPet", "content", "application/json", "schema"},
+			},
+			suffix: "Request",
+			want:   "NewPetRequest",
+		},
+		"multiple": {
+			paths: []_path{
+				{"components", "responses", "NotFound", "content", "application/json", "schema"},
+				{"components", "responses", "Gone", "content", "application/json", "schema"},
+			},
+			suffix: "Response",
+			want:   "CommonNotFoundResponse",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := tt.paths.componentSymbol(tt.suffix)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPaths_componentHeaderSymbol(t *testing.T) {
+	tests := map[string]struct {
+		paths paths
+		want  string
+	}{
+		"header": {
+			paths: []_path{
+				{"components", "headers", "X-Rate-Limit", "schema"},
+			},
+			want: "XRateLimitHeader",
+		},
+		"response header": {
+			paths: []_path{
+				{"components", "responses", "NotFound", "headers", "X-Rate-Limit", "schema"},
+			},
+			want: "NotFoundXRateLimitHeader",
+		},
+		"multiple": {
+			paths: []_path{
+				{"components", "headers", "X-Rate-Limit", "schema"},
+				{"components", "responses", "NotFound", "headers", "X-Rate-Limit", "schema"},
+			},
+			want: "CommonXRateLimitHeader",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := tt.paths.componentHeaderSymbol()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPaths_pathParameterSymbol(t *testing.T) {
+	tests := map[string]struct {
+		paths paths
+		names []string
+		want  string
+	}{
+		"single parameter": {
+			paths: []_path{
+				{"paths", "/v2/foo", "parameters", "0", "schema"},
+			},
+			names: []string{"filter"},
+			want:  "V2FooFilterParam",
+		},
+		"common name": {
+			paths: []_path{
+				{"paths", "/v2/foo", "parameters", "0", "schema"},
+				{"paths", "/v2/ping", "parameters", "0", "schema"},
+			},
+			names: []string{"filter", "filter"},
+			want:  "CommonFilterParam",
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := tt.paths.embeddedArraySymbol()
+			got, _, err := tt.paths.pathParameterSymbol(tt.names)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func TestPath_pointer(t *testing.T) {
+	assert.Equal(t, "/paths/~1v2~1foo/post/x~0y", _path{"paths", "/v2/foo", "post", "x~y"}.pointer())
+}
+
+func TestPaths_operationIDSymbol(t *testing.T) {
+	tests := map[string]struct {
+		paths        paths
+		operationIDs []string
+		suffix       string
+		want         string
+	}{
+		"request": {
+			paths: []_path{
+				{"paths", "/v2/mandate-imports", "post", "requestBody", "content", "application/json", "schema"},
+			},
+			operationIDs: []string{"createMandateImport"},
+			suffix:       "Request",
+			want:         "CreateMandateImportRequest",
+		},
+		"response": {
+			paths: []_path{
+				{"paths", "/v2/foo", "get", "responses", "200", "content", "application/json", "schema"},
+				{"paths", "/v2/foo", "get", "responses", "201", "content", "application/json", "schema"},
+			},
+			operationIDs: []string{"get-foo", "get-foo"},
+			suffix:       "Response",
+			want:         "GetFoo2xxResponse",
+		},
+		"different operation ids": {
+			paths: []_path{
+				{"paths", "/v2/foo", "post", "requestBody", "content", "application/json", "schema"},
+				{"paths", "/v2/foo", "put", "requestBody", "content", "application/json", "schema"},
+			},
+			operationIDs: []string{"createFoo", "updateFoo"},
+			suffix:       "Request",
+			want:         "",
+		},
+		"missing operation id": {
+			paths: []_path{
+				{"paths", "/v2/foo", "post", "requestBody", "content", "application/json", "schema"},
+			},
+			operationIDs: []string{""},
+			suffix:       "Request",
+			want:         "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.paths.operationIDSymbol(tt.operationIDs, tt.suffix))
+		})
+	}
+}
+
+func Test_sanitizeURLPath(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"simple path":        {in: "/v2/foo", want: "V2Foo"},
+		"dash":               {in: "/v2/mandate-imports", want: "V2MandateImports"},
+		"path parameter":     {in: "/v2/users/{userId}/items", want: "V2UsersByUserIdItems"},
+		"dot":                {in: "/v2/users/{userId}/items.json", want: "V2UsersByUserIdItemsJson"},
+		"underscore":         {in: "/search_v1", want: "SearchV1"},
+		"empty segments":     {in: "//v2//foo/", want: "V2Foo"},
+		"empty":              {in: "", want: ""},
+		"root":               {in: "/", want: ""},
+		"unicode":            {in: "/café/élan", want: "CaféÉlan"},
+		"header":             {in: "X-Rate-Limit", want: "XRateLimit"},
+		"camel case segment": {in: "/fooBar", want: "FooBar"},
+		"other punctuation":  {in: "/a:b/c;d/@e", want: "ABCDE"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizeURLPath(tt.in))
+		})
+	}
+}
+
+func Test_toIdentifier(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"already valid":     {in: "PostV2FooRequest", want: "PostV2FooRequest"},
+		"lower case":        {in: "updatePetBody", want: "updatePetBody"},
+		"underscore":        {in: "Pet_v1", want: "Pet_v1"},
+		"braces":            {in: "Pets{id}Request", want: "PetsByIdRequest"},
+		"dot":               {in: "pet.v1", want: "petV1"},
+		"leading digit":     {in: "404Response", want: "N404Response"},
+		"go reserved":       {in: "type", want: "typeSchema"},
+		"typescript":        {in: "any", want: "anySchema"},
+		"java reserved":     {in: "synchronized", want: "synchronizedSchema"},
+		"reserved not case": {in: "Type", want: "Type"},
+		"unicode":           {in: "Éleveur", want: "Éleveur"},
+		"nothing valid":     {in: "{}", want: "By"},
+		"empty":             {in: "-/.", want: ""},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, toIdentifier(tt.in))
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package spec
+
+import "fmt"
+
+// Policy limits which of the schemas found at a location are extracted.
+// Schemas which are not extracted are left inline, including any schemas
+// within them. The zero value extracts every schema found.
+type Policy struct {
+	// ObjectsOnly extracts only schemas of type object with properties
+	ObjectsOnly bool
+	// MinProperties skips objects with fewer properties
+	MinProperties int
+	// SkipRefArrays skips arrays whose items are a reference
+	SkipRefArrays bool
+}
+
+func (p Policy) allows(schema object) bool {
+	properties, _ := schema["properties"].(object)
+	switch schema["type"] {
+	case "object":
+		return len(properties) >= p.MinProperties && (!p.ObjectsOnly || len(properties) > 0)
+	case "array":
+		if items, ok := schema["items"].(object); ok && p.SkipRefArrays {
+			if _, ok := items["$ref"]; ok {
+				return false
+			}
+		}
+	}
+	return !p.ObjectsOnly
+}
+
+func checkPolicies(policies map[Location]Policy) error {
+	for location, policy := range policies {
+		if !isLocation(location) {
+			return fmt.Errorf("policy for unknown location %q", location)
+		}
+		if policy.MinProperties < 0 {
+			return fmt.Errorf("negative minProperties for %s", location)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_allows(t *testing.T) {
+	address := object{"type": "object", "properties": object{"street": object{"type": "string"}}}
+	refArray := object{"type": "array", "items": object{"$ref": "#/components/schemas/Pet"}}
+	tests := map[string]struct {
+		policy Policy
+		schema object
+		want   bool
+	}{
+		"default object":    {schema: address, want: true},
+		"default string":    {schema: object{"type": "string"}, want: true},
+		"default ref array": {schema: refArray, want: true},
+		"objects only": {
+			policy: Policy{ObjectsOnly: true},
+			schema: address,
+			want:   true,
+		},
+		"objects only string": {
+			policy: Policy{ObjectsOnly: true},
+			schema: object{"type": "string"},
+		},
+		"objects only without properties": {
+			policy: Policy{ObjectsOnly: true},
+			schema: object{"type": "object"},
+		},
+		"too few properties": {
+			policy: Policy{MinProperties: 2},
+			schema: address,
+		},
+		"enough properties": {
+			policy: Policy{MinProperties: 1},
+			schema: address,
+			want:   true,
+		},
+		"min properties ignores arrays": {
+			policy: Policy{MinProperties: 2},
+			schema: object{"type": "array", "items": object{"type": "string"}},
+			want:   true,
+		},
+		"ref array": {
+			policy: Policy{SkipRefArrays: true},
+			schema: refArray,
+		},
+		"inline array": {
+			policy: Policy{SkipRefArrays: true},
+			schema: object{"type": "array", "items": address},
+			want:   true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.allows(tt.schema))
+		})
+	}
+}
+
+func TestSpec_Transform_policies(t *testing.T) {
+	in := `
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          text/plain:
+            schema:
+              type: string
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/Pet'
+        201:
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  id: {type: string}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        tag:
+          type: object
+          properties:
+            name: {type: string}
+`
+	tests := map[string]struct {
+		policies map[Location]Policy
+		want     []string
+		wantErr  string
+	}{
+		"default": {
+			want: []string{"PostPetsRequest", "PostPets200Response", "PostPets201Response", "PetTag"},
+		},
+		"objects only": {
+			policies: map[Location]Policy{
+				LocationRequestBody:  {ObjectsOnly: true},
+				LocationResponseBody: {ObjectsOnly: true},
+			},
+			want: []string{"PostPets201Response", "PetTag"},
+		},
+		"skip ref arrays and small embedded objects": {
+			policies: map[Location]Policy{
+				LocationResponseBody:   {SkipRefArrays: true},
+				LocationEmbeddedObject: {MinProperties: 2},
+			},
+			want: []string{"PostPetsRequest", "PostPets201Response"},
+		},
+		"unknown location": {
+			policies: map[Location]Policy{"body": {}},
+			wantErr:  `policy for unknown location "body"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(in))
+			assert.NoError(t, err)
+			_, extractions, err := s.Transform(Options{Policies: tt.policies})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			var got []string
+			for _, e := range extractions {
+				got = append(got, e.Schema)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
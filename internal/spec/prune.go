@@ -0,0 +1,68 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+)
+
+// PruneOptions controls the behaviour of Prune. The zero value removes every
+// unreferenced schema and logs nothing.
+type PruneOptions struct {
+	// Keep are schemas which are kept, along with those they refer to, even
+	// if they are not referenced
+	Keep []string
+	// Log receives progress messages, if set.
+	Log io.Writer
+}
+
+// Prune removes the schemas of components.schemas which are not referenced,
+// directly or through other schemas, from elsewhere in the document, returning
+// the names of those removed in sorted order.
+func (s Spec) Prune(opts PruneOptions) (Spec, []string, error) {
+	log := opts.Log
+	if log == nil {
+		log = io.Discard
+	}
+	ret := s.copy()
+	found, _ := lookupPath(ret.object, _path{"components", "schemas"})
+	schemas, _ := found.(object)
+	for _, name := range opts.Keep {
+		if _, ok := schemas[name]; !ok {
+			return s, nil, fmt.Errorf("unknown schema %q", name)
+		}
+	}
+
+	graph := newSchemaGraph(findSchemaRefs(ret.object, nil))
+	reachable := graph.reachable(append([]string{""}, opts.Keep...)...)
+	for _, name := range opts.Keep {
+		reachable[name] = true
+	}
+	var removed []string
+	for _, k := range schemas.sortedKeys() {
+		name := fmt.Sprintf("%v", k)
+		if !reachable[name] {
+			delete(schemas, k)
+			removed = append(removed, name)
+			fmt.Fprintf(log, "Removed %s\n", name)
+		}
+	}
+	if len(removed) > 0 {
+		ret.removeSchemasIfEmpty()
+	}
+	return ret, removed, nil
+}
+
+// removeSchemasIfEmpty removes components.schemas, and then components, if
+// they are empty
+func (s Spec) removeSchemasIfEmpty() {
+	components, ok := s.object["components"].(object)
+	if !ok {
+		return
+	}
+	if schemas, ok := components["schemas"].(object); ok && len(schemas) == 0 {
+		delete(components, "schemas")
+		if len(components) == 0 {
+			delete(s.object, "components")
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpec_Prune(t *testing.T) {
+	input := `
+paths:
+  /pets:
+    post:
+      requestBody:
+        $ref: '#/components/requestBodies/NewPet'
+      callbacks:
+        onAdded:
+          '{$request.body#/callbackUrl}':
+            post:
+              requestBody:
+                content:
+                  application/json:
+                    schema:
+                      $ref: '#/components/schemas/Event'
+components:
+  requestBodies:
+    NewPet:
+      content:
+        application/json:
+          schema:
+            allOf:
+              - $ref: '#/components/schemas/Pet'
+  schemas:
+    Pet:
+      oneOf:
+        - $ref: '#/components/schemas/Cat'
+      discriminator:
+        propertyName: kind
+        mapping:
+          dog: '#/components/schemas/Dog'
+    Cat: {type: object}
+    Dog: {type: object}
+    Event:
+      type: object
+      properties:
+        name:
+          $ref: '#/components/schemas/Name/properties/value'
+    Name:
+      type: object
+      properties:
+        value: {type: string}
+    Orphan:
+      type: object
+      properties:
+        child:
+          $ref: '#/components/schemas/OrphanChild'
+    OrphanChild:
+      type: object
+      properties:
+        parent:
+          $ref: '#/components/schemas/Orphan'
+    Legacy:
+      type: object
+      properties:
+        id:
+          $ref: '#/components/schemas/LegacyID'
+    LegacyID: {type: string}
+`
+	tests := map[string]struct {
+		opts    PruneOptions
+		want    []string
+		wantErr string
+	}{
+		"unreferenced": {
+			want: []string{"Legacy", "LegacyID", "Orphan", "OrphanChild"},
+		},
+		"kept": {
+			opts: PruneOptions{Keep: []string{"Legacy"}},
+			want: []string{"Orphan", "OrphanChild"},
+		},
+		"unknown": {
+			opts:    PruneOptions{Keep: []string{"Missing"}},
+			wantErr: `unknown schema "Missing"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(input))
+			assert.NoError(t, err)
+			out, removed, err := s.Prune(tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, removed)
+			schemas, err := out.schemasNode()
+			assert.NoError(t, err)
+			for _, name := range tt.want {
+				assert.NotContains(t, schemas, name)
+			}
+			assert.Len(t, schemas, 9-len(tt.want))
+		})
+	}
+}
+
+func TestSpec_Prune_empty(t *testing.T) {
+	s, err := NewFromYaml(strings.NewReader("paths: {}\ncomponents:\n  schemas:\n    Pet: {type: object}\n"))
+	assert.NoError(t, err)
+	out, removed, err := s.Prune(PruneOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Pet"}, removed)
+	var sb strings.Builder
+	assert.NoError(t, out.ToYaml(&sb))
+	assert.Equal(t, "paths: {}\n", sb.String())
+}
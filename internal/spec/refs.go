@@ -0,0 +1,181 @@
+package spec
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schemaRef is a reference to a schema in components.schemas
+type schemaRef struct {
+	// name is the name of the schema
+	name string
+	// path is the path of the object holding the reference, or of the value
+	// of a discriminator mapping
+	path _path
+	// holder is the object holding the reference, or nil for the value of a
+	// discriminator mapping
+	holder object
+	// whole is set if the reference is to the whole schema, rather than to
+	// content within it
+	whole bool
+}
+
+// findSchemaRefs returns the references to components.schemas within v, at
+// path, including those of discriminator mappings
+func findSchemaRefs(v interface{}, path _path) []schemaRef {
+	var ret []schemaRef
+	switch val := v.(type) {
+	case []interface{}:
+		for i, item := range val {
+			ret = append(ret, findSchemaRefs(item, path.child(strconv.Itoa(i)))...)
+		}
+	case object:
+		if ref, ok := val["$ref"].(string); ok {
+			if name, whole, ok := refSchemaName(ref); ok {
+				ret = append(ret, schemaRef{name: name, path: path, holder: val, whole: whole})
+			}
+		}
+		if discriminator, ok := val["discriminator"].(object); ok {
+			mapping, _ := discriminator["mapping"].(object)
+			for _, k := range mapping.sortedKeys() {
+				ref, _ := mapping[k].(string)
+				if name, whole, ok := refSchemaName(ref); ok {
+					key := fmt.Sprintf("%v", k)
+					ret = append(ret, schemaRef{name: name, path: path.child("discriminator").child("mapping").child(key), whole: whole})
+				}
+			}
+		}
+		for _, k := range val.sortedKeys() {
+			ret = append(ret, findSchemaRefs(val[k], path.child(fmt.Sprintf("%v", k)))...)
+		}
+	}
+	return ret
+}
+
+// refSchemaName returns the name of the schema in components.schemas referred
+// to by ref, and whether ref is to the whole schema
+func refSchemaName(ref string) (string, bool, bool) {
+	rest := strings.TrimPrefix(ref, "#/components/schemas/")
+	if rest == ref || rest == "" {
+		return "", false, false
+	}
+	name, within, _ := strings.Cut(rest, "/")
+	return unescapePointer(name), within == "", true
+}
+
+// renameRefs replaces references to the schemas named in renames, including
+// those in discriminator mappings, with references to their new names.
+func renameRefs(v interface{}, renames map[string]string) {
+	rename := func(ref interface{}) (string, bool) {
+		s, ok := ref.(string)
+		if !ok {
+			return "", false
+		}
+		name, _, ok := refSchemaName(s)
+		to, renamed := renames[name]
+		if !ok || !renamed {
+			return "", false
+		}
+		// Keep any pointer to content within the schema
+		_, within, _ := strings.Cut(strings.TrimPrefix(s, "#/components/schemas/"), "/")
+		if within != "" {
+			within = "/" + within
+		}
+		return "#/components/schemas/" + to + within, true
+	}
+	switch val := v.(type) {
+	case []interface{}:
+		for _, item := range val {
+			renameRefs(item, renames)
+		}
+	case object:
+		if ref, ok := rename(val["$ref"]); ok {
+			val["$ref"] = ref
+		}
+		if discriminator, ok := val["discriminator"].(object); ok {
+			if mapping, ok := discriminator["mapping"].(object); ok {
+				for k, v := range mapping {
+					if ref, ok := rename(v); ok {
+						mapping[k] = ref
+					}
+				}
+			}
+		}
+		for _, item := range val {
+			renameRefs(item, renames)
+		}
+	}
+}
+
+// schemaGraph holds the schemas referred to from each schema in
+// components.schemas, and from the rest of the document under ""
+type schemaGraph map[string]map[string]bool
+
+func newSchemaGraph(refs []schemaRef) schemaGraph {
+	ret := schemaGraph{}
+	for _, r := range refs {
+		from := ""
+		if len(r.path) > 2 && r.path[0] == "components" && r.path[1] == "schemas" {
+			from = r.path[2]
+		}
+		if ret[from] == nil {
+			ret[from] = map[string]bool{}
+		}
+		ret[from][r.name] = true
+	}
+	return ret
+}
+
+// reachable returns the schemas referred to, directly or indirectly, from
+// those given
+func (g schemaGraph) reachable(from ...string) map[string]bool {
+	ret := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		for to := range g[name] {
+			if !ret[to] {
+				ret[to] = true
+				visit(to)
+			}
+		}
+	}
+	for _, name := range from {
+		visit(name)
+	}
+	return ret
+}
+
+// cyclic reports whether the named schema refers to itself, directly or
+// indirectly
+func (g schemaGraph) cyclic(name string) bool {
+	return g.reachable(name)[name]
+}
+
+// dependenciesFirst returns names ordered so that each follows the names it
+// refers to, directly or indirectly. names must not be cyclic.
+func (g schemaGraph) dependenciesFirst(names []string) []string {
+	include := toSet(names)
+	visited := map[string]bool{}
+	var ret []string
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, to := range sortedNames(g[name]) {
+			if include[to] {
+				visit(to)
+			}
+		}
+		ret = append(ret, name)
+	}
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		visit(name)
+	}
+	return ret
+}
@@ -0,0 +1,314 @@
+package spec
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceWriter writes a document as yaml, copying the text it was read from
+// for every entry which is unchanged, so that whitespace and the exact text
+// of block scalars are kept. Only changed content is written by the yaml
+// encoder.
+type sourceWriter struct {
+	buf bytes.Buffer
+	// lines are the lines of the source text, each with its line break
+	lines  []string
+	indent int
+	// schemas is the components.schemas mapping of the source, within which
+	// the encoder uses schemasIndent, if set, as the existing schemas do
+	schemas       *yaml.Node
+	schemasIndent int
+	// lineBreak ends each line written by the encoder, as in the source
+	lineBreak string
+}
+
+// writeSourceYaml writes doc, copying from text wherever the nodes of doc are
+// those of text, in the same place. It returns false, having written
+// nothing, if the document is not a block mapping which can be written in
+// this way.
+func writeSourceYaml(writer io.Writer, doc *yaml.Node, text []byte, indent int) (bool, error) {
+	var src yaml.Node
+	if err := yaml.Unmarshal(text, &src); err != nil || len(src.Content) == 0 || len(doc.Content) == 0 {
+		return false, nil
+	}
+	root, srcRoot := doc.Content[0], src.Content[0]
+	if !isBlockMapping(root) || !isBlockMapping(srcRoot) || srcRoot.Column != 1 || hasMergeKey(srcRoot) {
+		return false, nil
+	}
+	w := sourceWriter{lines: strings.SplitAfter(string(text), "\n"), indent: indent, lineBreak: detectLineBreak(text)}
+	if components := lookupNode(srcRoot, _path{"components"}); components != nil && components.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(components.Content); i += 2 {
+			if components.Content[i].Value == "schemas" {
+				w.schemas = components.Content[i+1]
+				w.schemasIndent = schemasIndent(components.Content[i], w.schemas)
+			}
+		}
+	}
+	// Anything before the first key, such as comments, is kept as it was
+	w.copyLines(1, srcRoot.Line)
+	if err := w.mapping(root, srcRoot, 0, -1, len(w.lines)+1); err != nil {
+		return false, err
+	}
+	_, err := writer.Write(w.buf.Bytes())
+	return true, err
+}
+
+// mapping writes the block mapping n, whose source is src, with keys at
+// column col. An entry which is unchanged is written as the source lines
+// from its key to the next key of src, or to end for the last. If dash is not
+// -1, n is an item of a sequence whose dash is at that column.
+func (w *sourceWriter) mapping(n, src *yaml.Node, col, dash, end int) error {
+	if src == w.schemas && w.schemasIndent != 0 {
+		defer func(indent int) { w.indent = indent }(w.indent)
+		w.indent = w.schemasIndent
+	}
+	index := map[[2]int]int{}
+	for j := 0; j+1 < len(src.Content); j += 2 {
+		index[[2]int{src.Content[j].Line, src.Content[j].Column}] = j
+	}
+	// Blank lines ending the span of the last entry of src are kept even if
+	// the entry is not
+	last := len(src.Content) - 2
+	keptLast := false
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		first := dash >= 0 && i == 0
+		j, ok := index[[2]int{key.Line, key.Column}]
+		// Only the source line of the first key holds the dash of an item
+		if ok && sameNode(key, src.Content[j]) && (dash < 0 || first == (j == 0)) {
+			next := end
+			if j+2 < len(src.Content) {
+				next = src.Content[j+2].Line
+			}
+			srcValue := src.Content[j+1]
+			if sameNode(value, srcValue) {
+				w.copyLines(key.Line, next)
+				keptLast = keptLast || j == last
+				continue
+			}
+			if w.nested(value, srcValue, key) {
+				w.copyLines(key.Line, key.Line+1)
+				if err := w.children(value, srcValue, key.Line+1, next); err != nil {
+					return err
+				}
+				keptLast = keptLast || j == last
+				continue
+			}
+		}
+		itemDash := -1
+		if first {
+			itemDash = dash
+		}
+		pair := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{key, value}}
+		if err := w.encode(pair, col, itemDash); err != nil {
+			return err
+		}
+	}
+	if !keptLast {
+		w.copyTrailingLines(src.Content[last].Line, end, col)
+	}
+	return nil
+}
+
+// sequence writes the block sequence n, whose source is src. An item which is
+// unchanged is written as the source lines from its dash to the next dash of
+// src, or to end for the last.
+func (w *sourceWriter) sequence(n, src *yaml.Node, end int) error {
+	dash := w.dashColumn(src.Content[0])
+	last := len(src.Content) - 1
+	keptLast := false
+	for i, item := range n.Content {
+		if i < len(src.Content) {
+			srcItem := src.Content[i]
+			next := end
+			if i+1 < len(src.Content) {
+				next = src.Content[i+1].Line
+			}
+			if sameNode(item, srcItem) {
+				w.copyLines(srcItem.Line, next)
+				keptLast = keptLast || i == last
+				continue
+			}
+			if isBlockMapping(item) && isBlockMapping(srcItem) && len(item.Content) > 0 &&
+				sameProperties(item, srcItem) && !hasMergeKey(srcItem) {
+				if err := w.mapping(item, srcItem, srcItem.Column-1, w.dashColumn(srcItem), next); err != nil {
+					return err
+				}
+				keptLast = keptLast || i == last
+				continue
+			}
+		}
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{item}}
+		if err := w.encode(seq, dash, -1); err != nil {
+			return err
+		}
+	}
+	if !keptLast {
+		w.copyTrailingLines(src.Content[last].Line, end, dash)
+	}
+	return nil
+}
+
+// nested reports whether value, whose source srcValue follows key on a line
+// of its own, can be written entry by entry below the source line of key.
+func (w *sourceWriter) nested(value, srcValue, key *yaml.Node) bool {
+	if value.Kind != srcValue.Kind || !sameProperties(value, srcValue) ||
+		srcValue.Line <= key.Line || len(value.Content) == 0 || len(srcValue.Content) == 0 {
+		return false
+	}
+	switch value.Kind {
+	case yaml.MappingNode:
+		return isBlockMapping(value) && isBlockMapping(srcValue) && !hasMergeKey(srcValue)
+	case yaml.SequenceNode:
+		if value.Style&yaml.FlowStyle != 0 || srcValue.Style&yaml.FlowStyle != 0 {
+			return false
+		}
+		for _, item := range srcValue.Content {
+			if w.dashColumn(item) < 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// children writes the content of value, whose source is src, starting from
+// the line from. Lines before the first entry of src, such as its comments,
+// are kept if that entry is.
+func (w *sourceWriter) children(value, src *yaml.Node, from, end int) error {
+	if value.Kind == yaml.SequenceNode {
+		w.copyLines(from, src.Content[0].Line)
+		return w.sequence(value, src, end)
+	}
+	if sameNode(value.Content[0], src.Content[0]) {
+		w.copyLines(from, src.Content[0].Line)
+	}
+	return w.mapping(value, src, src.Content[0].Column-1, -1, end)
+}
+
+// dashColumn returns the column of the dash before the sequence item n, if it
+// is on the same line with only whitespace around it, or else -1.
+func (w *sourceWriter) dashColumn(n *yaml.Node) int {
+	if n.Line < 1 || n.Line > len(w.lines) || n.Column < 1 || n.Column-1 > len(w.lines[n.Line-1]) {
+		return -1
+	}
+	prefix := w.lines[n.Line-1][:n.Column-1]
+	if strings.TrimSpace(prefix) != "-" {
+		return -1
+	}
+	return strings.Index(prefix, "-")
+}
+
+// copyLines copies the source lines from up to but not including to
+func (w *sourceWriter) copyLines(from, to int) {
+	for line := from; line < to && line <= len(w.lines); line++ {
+		w.buf.WriteString(w.lines[line-1])
+	}
+}
+
+// copyTrailingLines copies the blank lines, and comments left of col, which
+// end the source lines after from up to end. These separate what follows
+// rather than belonging to the entry at from, which has not been copied.
+func (w *sourceWriter) copyTrailingLines(from, end, col int) {
+	start := end
+	for line := end - 1; line > from && line <= len(w.lines); line-- {
+		text := w.lines[line-1]
+		trimmed := strings.TrimLeft(text, " \t")
+		if strings.TrimSpace(text) != "" && !(strings.HasPrefix(trimmed, "#") && len(text)-len(trimmed) < col) {
+			break
+		}
+		start = line
+	}
+	if start < end {
+		w.newline()
+	}
+	w.copyLines(start, end)
+}
+
+// newline ends the last line written, if it has no line break
+func (w *sourceWriter) newline() {
+	if w.buf.Len() > 0 && !bytes.HasSuffix(w.buf.Bytes(), []byte("\n")) {
+		w.buf.WriteString(w.lineBreak)
+	}
+}
+
+// encode writes n with the yaml encoder, indented to col. If dash is not -1,
+// the first line is written after a dash at that column.
+func (w *sourceWriter) encode(n *yaml.Node, col, dash int) error {
+	var encoded bytes.Buffer
+	encoder := yaml.NewEncoder(&encoded)
+	encoder.SetIndent(w.indent)
+	if err := encoder.Encode(n); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	w.newline()
+	for i, line := range strings.Split(strings.TrimSuffix(encoded.String(), "\n"), "\n") {
+		switch {
+		case i == 0 && dash >= 0:
+			w.buf.WriteString(strings.Repeat(" ", dash) + "-" + strings.Repeat(" ", col-dash-1))
+		case line != "":
+			w.buf.WriteString(strings.Repeat(" ", col))
+		}
+		w.buf.WriteString(line + w.lineBreak)
+	}
+	return nil
+}
+
+// detectLineBreak returns the line break used by the first line of text
+func detectLineBreak(text []byte) string {
+	if i := bytes.IndexByte(text, '\n'); i > 0 && text[i-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+func isBlockMapping(n *yaml.Node) bool {
+	return n.Kind == yaml.MappingNode && n.Style&yaml.FlowStyle == 0 && len(n.Content) > 0
+}
+
+func hasMergeKey(n *yaml.Node) bool {
+	for i := 0; i < len(n.Content); i += 2 {
+		if n.Content[i].Tag == "!!merge" {
+			return true
+		}
+	}
+	return false
+}
+
+// sameProperties reports whether a and b are written with the same anchor
+// and tag, which are part of the line before their content
+func sameProperties(a, b *yaml.Node) bool {
+	return a.Anchor == b.Anchor && a.Tag == b.Tag && a.Style&yaml.TaggedStyle == b.Style&yaml.TaggedStyle
+}
+
+// sameNode reports whether a and b are the same content read from the same
+// place in the same text.
+func sameNode(a, b *yaml.Node) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	if a.Kind != b.Kind || a.Style != b.Style || a.Tag != b.Tag || a.Value != b.Value || a.Anchor != b.Anchor ||
+		a.HeadComment != b.HeadComment || a.LineComment != b.LineComment || a.FootComment != b.FootComment ||
+		a.Line != b.Line || a.Column != b.Column || len(a.Content) != len(b.Content) {
+		return false
+	}
+	if a.Kind == yaml.AliasNode {
+		return a.Alias != nil && b.Alias != nil && a.Alias.Line == b.Alias.Line && a.Alias.Column == b.Alias.Column
+	}
+	for i := range a.Content {
+		if !sameNode(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -3,252 +3,915 @@ package spec
 import (
 	"fmt"
 	"io"
-	"strconv"
+	"reflect"
 	"strings"
-	"unicode"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
+// operations matches the keys of a path item which are operations, as opposed
+// to path-level fields such as parameters, summary or servers.
+const operations = "[get,put,post,delete,options,head,patch,trace]"
+
+// objectFilter limits a search path to objects
+const objectFilter = ".[?(@type=='object')]"
+
 const (
-	requestSearchPath             = "$.paths.*.*.requestBody.content.*.schema"
-	responseSearchPath            = "$.paths.*.*.responses.*.content.*.schema"
-	embeddedObjectSearchPath      = "$.components.schemas.*.properties.*.[?(@type=='object')]"
-	embeddedArrayObjectSearchPath = "$.components.schemas.*.*.*.*.[?(@type=='object')]"
+	requestSearchPath                     = "$.paths.*." + operations + ".requestBody.content.*.schema"
+	responseSearchPath                    = "$.paths.*." + operations + ".responses.*.content.*.schema"
+	parameterSearchPath                   = "$.paths.*." + operations + ".parameters.*.schema" + objectFilter
+	parameterContentSearchPath            = "$.paths.*." + operations + ".parameters.*.content.*.schema" + objectFilter
+	pathParameterSearchPath               = "$.paths.*.parameters.*.schema" + objectFilter
+	pathParameterContentSearchPath        = "$.paths.*.parameters.*.content.*.schema" + objectFilter
+	responseHeaderSearchPath              = "$.paths.*." + operations + ".responses.*.headers.*.schema" + objectFilter
+	responseHeaderContentSearchPath       = "$.paths.*." + operations + ".responses.*.headers.*.content.*.schema" + objectFilter
+	callbackRequestSearchPath             = "$.paths.*." + operations + ".callbacks.*.*." + operations + ".requestBody.content.*.schema"
+	callbackResponseSearchPath            = "$.paths.*." + operations + ".callbacks.*.*." + operations + ".responses.*.content.*.schema"
+	callbackParameterSearchPath           = "$.paths.*." + operations + ".callbacks.*.*." + operations + ".parameters.*.schema" + objectFilter
+	callbackParameterContentPath          = "$.paths.*." + operations + ".callbacks.*.*." + operations + ".parameters.*.content.*.schema" + objectFilter
+	componentParameterSearchPath          = "$.components.parameters.*.schema" + objectFilter
+	componentRequestBodySearchPath        = "$.components.requestBodies.*.content.*.schema"
+	componentResponseSearchPath           = "$.components.responses.*.content.*.schema"
+	componentResponseHeaderSearchPath     = "$.components.responses.*.headers.*.schema" + objectFilter
+	componentResponseHeaderContentPath    = "$.components.responses.*.headers.*.content.*.schema" + objectFilter
+	componentHeaderSearchPath             = "$.components.headers.*.schema" + objectFilter
+	componentHeaderContentSearchPath      = "$.components.headers.*.content.*.schema" + objectFilter
+	componentCallbackRequestPath          = "$.components.callbacks.*.*." + operations + ".requestBody.content.*.schema"
+	componentCallbackResponsePath         = "$.components.callbacks.*.*." + operations + ".responses.*.content.*.schema"
+	componentCallbackParameterPath        = "$.components.callbacks.*.*." + operations + ".parameters.*.schema" + objectFilter
+	componentCallbackParameterContentPath = "$.components.callbacks.*.*." + operations + ".parameters.*.content.*.schema" + objectFilter
+	refPathItemSearchPath                 = "$.paths.*"
 )
 
-type Spec struct{ object }
+// extraction describes one location of inline schemas which are moved to
+// components.schemas, and how they are named.
+type extraction struct {
+	location    Location
+	description string
+	find        func(s Spec) []objectWithPath
+	symbol      func(t *transformer, val objectWithPaths) (string, Reason, error)
+	// data returns the data available to a naming template
+	data func(s Spec, val objectWithPaths) (NameData, error)
+	// items finds the inline object items of arrays at the location, which
+	// are extracted after the location itself
+	items func(s Spec) []objectWithPath
+}
+
+// topLevelExtractions are made once, before any embedded schemas are
+// extracted from components.schemas.
+var topLevelExtractions = []extraction{
+	{
+		location:    LocationRequestBody,
+		description: "request schema",
+		find:        searchPaths(requestSearchPath),
+		items:       searchItems(requestSearchPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			if t.opts.UseOperationID {
+				if symbol := val.paths.operationIDSymbol(t.operationIDs(val.paths), "Request"); symbol != "" {
+					return symbol, ReasonUnique, nil
+				}
+			}
+			return val.paths.requestSymbol()
+		},
+		data: requestNameData,
+	},
+	{
+		location:    LocationResponseBody,
+		description: "response schema",
+		find:        searchPaths(responseSearchPath),
+		items:       searchItems(responseSearchPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			if t.opts.UseOperationID {
+				if symbol := val.paths.operationIDSymbol(t.operationIDs(val.paths), "Response"); symbol != "" {
+					return symbol, ReasonUnique, nil
+				}
+			}
+			return val.paths.responseSymbol()
+		},
+		data: responseNameData,
+	},
+	{
+		location:    LocationParameter,
+		description: "parameter schema",
+		find:        searchPaths(parameterSearchPath, parameterContentSearchPath),
+		items:       searchItems(parameterSearchPath, parameterContentSearchPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.parameterSymbol(t.parameterNames(val.paths))
+		},
+		data: parameterNameData,
+	},
+	{
+		location:    LocationPathParameter,
+		description: "path parameter schema",
+		find:        searchPaths(pathParameterSearchPath, pathParameterContentSearchPath),
+		items:       searchItems(pathParameterSearchPath, pathParameterContentSearchPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.pathParameterSymbol(t.parameterNames(val.paths))
+		},
+		data: pathParameterNameData,
+	},
+	{
+		location:    LocationResponseHeader,
+		description: "response header schema",
+		find:        searchPaths(responseHeaderSearchPath, responseHeaderContentSearchPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.headerSymbol()
+		},
+		data: headerNameData,
+	},
+	{
+		location:    LocationCallbackRequestBody,
+		description: "callback request schema",
+		find:        searchPaths(callbackRequestSearchPath, componentCallbackRequestPath),
+		items:       searchItems(callbackRequestSearchPath, componentCallbackRequestPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.callbackSymbol("Request")
+		},
+		data: callbackNameData,
+	},
+	{
+		location:    LocationCallbackResponseBody,
+		description: "callback response schema",
+		find:        searchPaths(callbackResponseSearchPath, componentCallbackResponsePath),
+		items:       searchItems(callbackResponseSearchPath, componentCallbackResponsePath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.callbackSymbol("Response")
+		},
+		data: callbackNameData,
+	},
+	{
+		location:    LocationCallbackParameter,
+		description: "callback parameter schema",
+		find:        searchPaths(callbackParameterSearchPath, callbackParameterContentPath, componentCallbackParameterPath, componentCallbackParameterContentPath),
+		items:       searchItems(callbackParameterSearchPath, callbackParameterContentPath, componentCallbackParameterPath, componentCallbackParameterContentPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.callbackParameterSymbol(t.parameterNames(val.paths))
+		},
+		data: callbackParameterNameData,
+	},
+	{
+		location:    LocationComponentParameter,
+		description: "component parameter schema",
+		find:        searchPaths(componentParameterSearchPath),
+		items:       searchItems(componentParameterSearchPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.componentSymbol("Param")
+		},
+		data: componentNameData,
+	},
+	{
+		location:    LocationComponentRequestBody,
+		description: "component request body schema",
+		find:        searchPaths(componentRequestBodySearchPath),
+		items:       searchItems(componentRequestBodySearchPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.componentSymbol("Request")
+		},
+		data: componentNameData,
+	},
+	{
+		location:    LocationComponentResponse,
+		description: "component response schema",
+		find:        searchPaths(componentResponseSearchPath),
+		items:       searchItems(componentResponseSearchPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.componentSymbol("Response")
+		},
+		data: componentNameData,
+	},
+	{
+		location:    LocationComponentHeader,
+		description: "component header schema",
+		find:        searchPaths(componentResponseHeaderSearchPath, componentResponseHeaderContentPath, componentHeaderSearchPath, componentHeaderContentSearchPath),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.componentHeaderSymbol()
+		},
+		data: componentHeaderNameData,
+	},
+}
+
+// embeddedExtractions are repeated until nothing more is found, since each
+// extracted schema may contain further embedded schemas.
+var embeddedExtractions = []extraction{
+	embeddedExtraction(LocationEmbeddedObject, "objects"),
+	embeddedExtraction(LocationEmbeddedArrayObject, "array objects"),
+	embeddedExtraction(LocationComposition, "composition objects"),
+}
+
+// embeddedExtraction extracts the inline objects at location, found by
+// walking every subschema of each schema in components.schemas
+func embeddedExtraction(location Location, description string) extraction {
+	return extraction{
+		location:    location,
+		description: description,
+		find:        findSubschemas(location),
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			return val.paths.subschemaSymbol(t.subschemaDiscriminatorValue(val.paths[0], val.object))
+		},
+		data: subschemaNameData,
+	}
+}
+
+// Spec is an openapi document. Where it was read from a file, the original
+// node tree is kept so that output preserves key order, comments and styles
+// for anything which has not been changed.
+type Spec struct {
+	object
+	node   *yaml.Node
+	indent int
+	// json is set if the document was read as JSON, whose flow style is not
+	// kept when writing yaml
+	json bool
+	// origins records the original location of each extracted schema
+	origins map[string]_path
+	// source is the document in which the origins are found, if not node
+	source *yaml.Node
+	// text is the yaml the document was read from, which is written as it
+	// was while the content is unchanged
+	text []byte
+}
 
 func NewFromYaml(reader io.Reader) (*Spec, error) {
-	ret := Spec{}
-	err := yaml.NewDecoder(reader).Decode(&ret.object)
+	content, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, err
 	}
-	return &ret, nil
+	ret, err := newFromNode(content)
+	if err != nil {
+		return nil, err
+	}
+	ret.indent = detectIndent(content)
+	ret.text = content
+	return ret, nil
+}
+
+func newFromNode(content []byte) (*Spec, error) {
+	var node yaml.Node
+	err := yaml.Unmarshal(content, &node)
+	if err != nil {
+		return nil, err
+	}
+	return newFromDocument(&node)
+}
+
+// newFromDocument returns the spec for a parsed document node
+func newFromDocument(node *yaml.Node) (*Spec, error) {
+	if node.Kind == 0 {
+		return nil, io.EOF
+	}
+	v, err := valueFromNode(node)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(object)
+	if !ok {
+		return nil, fmt.Errorf("expected mapping at top level")
+	}
+	return &Spec{object: obj, node: node}, nil
 }
 
 func (s Spec) ToYaml(writer io.Writer) error {
-	return yaml.NewEncoder(writer).Encode(&s.object)
-}
-
-func (s Spec) Transform() Spec {
-	requests := removeRefs(s.findStringPath(requestSearchPath))
-	groupedRequests := groupObjects(requests)
-	responses := removeRefs(s.findStringPath(responseSearchPath))
-	groupedResponses := groupObjects(responses)
-	fmt.Printf("Found %d embedded request schema in %d groups\n", len(requests), len(groupedRequests))
-	fmt.Printf("Found %d embedded response schema in %d groups\n", len(responses), len(groupedResponses))
-
-	for _, val := range groupedRequests {
-		symbol := s.findMatchingSchema(val.object)
-		if symbol == "" {
-			var err error
-			symbol, err = val.paths.requestSymbol()
-			if err != nil {
-				panic(err)
+	if s.text != nil && s.unchanged() {
+		// yaml.v3 does not keep all whitespace, nor the exact text of block
+		// scalars
+		_, err := writer.Write(s.text)
+		return err
+	}
+	node, err := s.toNode()
+	if err != nil {
+		return err
+	}
+	if s.json {
+		node = blockStyle(copyNode(node, map[*yaml.Node]*yaml.Node{}))
+	}
+	indent := s.indent
+	if indent == 0 {
+		indent = 2
+	}
+	if s.text != nil && !s.json {
+		// Unchanged parts of the document are copied from the text, since
+		// yaml.v3 does not keep all whitespace
+		if written, err := writeSourceYaml(writer, node, s.text, indent); written || err != nil {
+			return err
+		}
+	}
+	encoder := yaml.NewEncoder(writer)
+	encoder.SetIndent(indent)
+	err = encoder.Encode(node)
+	if err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// unchanged reports whether the content is that of the text it was read from
+func (s Spec) unchanged() bool {
+	orig, err := newFromNode(s.text)
+	return err == nil && reflect.DeepEqual(orig.object, s.object)
+}
+
+// toNode returns the document node for the current content, reusing the nodes
+// of the original document wherever the content is unchanged.
+func (s Spec) toNode() (*yaml.Node, error) {
+	if s.node == nil || len(s.node.Content) == 0 {
+		return newNode(s.object)
+	}
+	root, err := mergeNode(s.node.Content[0], s.object, nil, s.originalNode)
+	if err != nil {
+		return nil, err
+	}
+	ret := *s.node
+	ret.Content = []*yaml.Node{root}
+	return &ret, nil
+}
+
+// copy returns a deep copy of the spec, sharing only the original document
+// node, which is never modified.
+func (s Spec) copy() Spec {
+	ret := s
+	ret.object = copyObject(s.object)
+	ret.origins = map[string]_path{}
+	for k, v := range s.origins {
+		ret.origins[k] = v
+	}
+	return ret
+}
+
+// Equal reports whether s has the same content as other, regardless of
+// formatting.
+func (s Spec) Equal(other Spec) bool {
+	return reflect.DeepEqual(s.object, other.object)
+}
+
+// originalNode returns the node in the original document for path, following
+// extracted schemas back to where they were found.
+func (s Spec) originalNode(path _path) *yaml.Node {
+	if len(path) >= 3 && path[0] == "components" && path[1] == "schemas" {
+		if from, ok := s.origins[path[2]]; ok {
+			orig := s
+			if s.source != nil {
+				orig.node, orig.source = s.source, nil
 			}
+			return orig.originalNode(append(append(_path{}, from...), path[3:]...))
+		}
+	}
+	return lookupNode(s.node, path)
+}
+
+// originalPath returns the path in the original document of the content at
+// path, following extracted schemas back to where they were found.
+func (s Spec) originalPath(path _path) _path {
+	for len(path) >= 3 && path[0] == "components" && path[1] == "schemas" {
+		from, ok := s.origins[path[2]]
+		if !ok {
+			break
+		}
+		path = append(append(_path{}, from...), path[3:]...)
+	}
+	return path
+}
+
+// transformer holds the state of a single Transform
+type transformer struct {
+	Spec
+	opts    Options
+	log     io.Writer
+	naming  naming
+	compare *canonicalizer
+	index   *schemaIndex
+	// searched holds the schemas already searched by each embedded
+	// extraction, which need not be searched again
+	searched    map[Location]map[string]bool
+	extractions []Extraction
+}
 
-			symbol = s.uniqueSymbol(symbol)
-			s.addObjectSchema(val.object, symbol)
+// Transform moves inline schemas to components.schemas, replacing them with
+// references, and returns the transformed copy of the spec along with a
+// description of each extraction.
+func (s Spec) Transform(opts Options) (Spec, []Extraction, error) {
+	t := transformer{Spec: s.copy(), opts: opts, log: opts.Log}
+	if t.log == nil {
+		t.log = io.Discard
+	}
+	var err error
+	t.naming, err = parseNaming(opts.Naming)
+	if err != nil {
+		return s, nil, err
+	}
+	if err = checkPolicies(opts.Policies); err != nil {
+		return s, nil, err
+	}
+	if err = checkLocations(opts.Locations); err != nil {
+		return s, nil, err
+	}
+	_, hadComponents := t.object["components"]
+	_, hadSchemas := lookupPath(t.object, _path{"components", "schemas"})
+	err = t.transform()
+	if !hadSchemas {
+		// Nothing extracted leaves the document as it was
+		t.removeCreatedSchemas(hadComponents)
+	}
+	return t.Spec, t.extractions, err
+}
+
+// removeCreatedSchemas removes components.schemas, which was not in the
+// document, if nothing was added to it, and components too unless it was.
+func (s Spec) removeCreatedSchemas(hadComponents bool) {
+	components, ok := s.object["components"].(object)
+	if !ok {
+		return
+	}
+	if schemas, ok := components["schemas"].(object); ok && len(schemas) == 0 {
+		delete(components, "schemas")
+		if len(components) == 0 && !hadComponents {
+			delete(s.object, "components")
+		}
+	}
+}
+
+func (t *transformer) transform() error {
+	schemas, err := t.schemasNode()
+	if err != nil {
+		return err
+	}
+	t.compare = t.opts.Comparator.canonicalizer(schemas)
+	t.index = newSchemaIndex(t.compare, schemas)
+	t.searched = map[Location]map[string]bool{}
+	refs := t.resolvePathItemRefs()
+	defer t.restorePathItemRefs(refs)
+	for _, e := range topLevelExtractions {
+		if t.opts.InlineArrays {
+			e.find = withoutArrays(e.find)
+		}
+		if _, err := t.extract(e, t.Spec, ""); err != nil {
+			return err
+		}
+		if e.items == nil {
+			continue
+		}
+		if _, err := t.extract(e.itemExtraction(), t.Spec, ""); err != nil {
+			return err
 		}
-		s.replaceWithRefs(val.paths, symbol)
 	}
-	for _, val := range groupedResponses {
-		symbol := s.findMatchingSchema(val.object)
-		if symbol == "" {
-			var err error
-			symbol, err = val.paths.responseSymbol()
+
+	// We need to do this iteratively since there may be more than one level of embedded object
+	fmt.Fprintf(t.log, "Checking components.schemas for embedded schemas:\n")
+	for i := 1; ; i++ {
+		fmt.Fprintf(t.log, "\tIteration %d:\n", i)
+		// Each search is made after the previous extraction so that an object
+		// is never extracted from within one which has already been replaced.
+		// Only schemas added since the last search need to be searched again.
+		found := 0
+		for _, e := range embeddedExtractions {
+			n, err := t.extract(e, t.unsearched(e.location), "\t\t")
 			if err != nil {
-				panic(err)
+				return err
 			}
-			symbol = s.uniqueSymbol(symbol)
-			s.addObjectSchema(val.object, symbol)
+			found += n
+		}
+		if found == 0 {
+			return nil
 		}
-		s.replaceWithRefs(val.paths, symbol)
 	}
+}
 
-	// We need to do this iteratively since there may be more than one level of embedded object
-	fmt.Printf("Checking components.schemas for embedded schemas:\n")
-	for i:=1;;i++{
-		fmt.Printf("\tIteration %d:\n", i)
-		embeddedObjects := s.findStringPath(embeddedObjectSearchPath)
-		groupedEmbeddedObjects := groupObjects(embeddedObjects)
-		embeddedArrayObjects := s.findStringPath(embeddedArrayObjectSearchPath)
-		groupedEmbeddedArrayObjects := groupObjects(embeddedArrayObjects)
-		fmt.Printf("\t\tFound %d embedded objects in %d groups\n", len(embeddedObjects), len(groupedEmbeddedObjects))
-		fmt.Printf("\t\tFound %d embedded array objects in %d groups\n", len(embeddedArrayObjects), len(groupedEmbeddedArrayObjects))
-		if len(embeddedObjects) == 0 && len(embeddedArrayObjects) == 0 {
-			break
+// pathItemRef is a path item defined by a reference to components.pathItems
+type pathItemRef struct {
+	// item is the path item holding the reference
+	item object
+	// path is the path of the path item, and target that of the path item
+	// it refers to
+	path, target _path
+}
+
+// resolvePathItemRefs puts the path item referred to in place of each path
+// item defined by a local reference to components.pathItems, so that its
+// schemas are found, and named, as if defined there. A path item referred to
+// more than once is only put in place of the first, so that it is transformed
+// once.
+func (t *transformer) resolvePathItemRefs() []pathItemRef {
+	var ret []pathItemRef
+	resolved := map[string]bool{}
+	paths, _ := t.object["paths"].(object)
+	for _, item := range t.findStringPath(refPathItemSearchPath) {
+		ref, ok := item.object["$ref"].(string)
+		if !ok {
+			continue
+		}
+		endpoint := item.path[len(item.path)-1]
+		if !strings.HasPrefix(ref, "#/components/pathItems/") {
+			fmt.Fprintf(t.log, "Skipping path item %s which references %s\n", endpoint, ref)
+			continue
+		}
+		if resolved[ref] {
+			fmt.Fprintf(t.log, "Skipping path item %s which references %s, already transformed\n", endpoint, ref)
+			continue
+		}
+		target := pointerPath(strings.TrimPrefix(ref, "#"))
+		found, _ := lookupPath(t.object, target)
+		content, ok := found.(object)
+		if !ok {
+			fmt.Fprintf(t.log, "Skipping path item %s whose reference %s is not found\n", endpoint, ref)
+			continue
 		}
-		for _, val := range groupedEmbeddedObjects {
-			symbol := s.findMatchingSchema(val.object)
-			if symbol == "" {
-				var err error
-				symbol, err = val.paths.embeddedSymbol()
-				if err != nil {
-					panic(err)
+		resolved[ref] = true
+		paths[paths.key(endpoint)] = content
+		ret = append(ret, pathItemRef{item: item.object, path: item.path, target: target})
+	}
+	return ret
+}
+
+// restorePathItemRefs puts back the references replaced by
+// resolvePathItemRefs, and gives the pointers and origins of the schemas
+// extracted from the path items they refer to.
+func (t *transformer) restorePathItemRefs(refs []pathItemRef) {
+	paths, _ := t.object["paths"].(object)
+	for _, r := range refs {
+		paths[paths.key(r.path[len(r.path)-1])] = r.item
+		prefix := r.path.pointer() + "/"
+		for i := range t.extractions {
+			for j, pointer := range t.extractions[i].Pointers {
+				if strings.HasPrefix(pointer, prefix) {
+					t.extractions[i].Pointers[j] = r.target.pointer() + "/" + strings.TrimPrefix(pointer, prefix)
 				}
+			}
+		}
+		for name, from := range t.origins {
+			if len(from) > len(r.path) && reflect.DeepEqual(from[:len(r.path)], r.path) {
+				t.origins[name] = append(append(_path{}, r.target...), from[len(r.path):]...)
+			}
+		}
+	}
+}
+
+// unsearched returns a view of the spec containing only the schemas in
+// components.schemas which have not yet been searched for location, and marks
+// them as searched.
+func (t *transformer) unsearched(location Location) Spec {
+	searched := t.searched[location]
+	if searched == nil {
+		searched = map[string]bool{}
+		t.searched[location] = searched
+	}
+	schemas := object{}
+	for name, schema := range t.index.schemas {
+		key := fmt.Sprintf("%v", name)
+		if !searched[key] {
+			schemas[name] = schema
+			searched[key] = true
+		}
+	}
+	return Spec{object: object{"components": object{"schemas": schemas}}}
+}
+
+// extract finds and extracts the schemas described by e within s, returning
+// the number found.
+func (t *transformer) extract(e extraction, s Spec, indent string) (int, error) {
+	if !t.opts.searches(e.location) {
+		return 0, nil
+	}
+	found := e.find(s)
+	if policy, ok := t.opts.Policies[e.location]; ok {
+		allowed := filter(found, func(o objectWithPath) bool { return policy.allows(o.object) })
+		if skipped := len(found) - len(allowed); skipped > 0 {
+			fmt.Fprintf(t.log, "%sSkipping %d %s by policy\n", indent, skipped, e.description)
+		}
+		found = allowed
+	}
+	grouped := groupObjects(found, t.compare.canonical)
+	fmt.Fprintf(t.log, "%sFound %d embedded %s in %d groups\n", indent, len(found), e.description, len(grouped))
+	err := t.extractGroups(e, grouped)
+	return len(found), err
+}
 
-				symbol = s.uniqueSymbol(symbol)
-				s.addObjectSchema(val.object, symbol)
+// extractGroups moves each group of identical schemas to components.schemas,
+// reusing an existing schema where one matches, and replaces every occurrence
+// with a reference.
+func (t *transformer) extractGroups(e extraction, groups []objectWithPaths) error {
+	for _, val := range groups {
+		symbol := t.index.find(val.object)
+		extraction := Extraction{
+			Schema:   symbol,
+			Location: e.location,
+			Pointers: t.inputPointers(val.paths),
+			Reason:   ReasonReused,
+			Reused:   symbol != "",
+		}
+		if !extraction.Reused {
+			candidate, reason, err := t.symbol(e, val)
+			if err != nil {
+				return &TransformError{Pointer: val.paths[0].pointer(), Reason: "cannot name schema", Err: err}
 			}
-			s.replaceWithRefs(val.paths, symbol)
-		}
-		for _, val := range groupedEmbeddedArrayObjects {
-			symbol := s.findMatchingSchema(val.object)
-			if symbol == "" {
-				var err error
-				symbol, err = val.paths.embeddedArraySymbol()
-				if err != nil {
-					panic(err)
-				}
 
-				symbol = s.uniqueSymbol(symbol)
-				s.addObjectSchema(val.object, symbol)
+			symbol, err = t.uniqueSymbol(e, val, candidate)
+			if err != nil {
+				return &TransformError{Pointer: val.paths[0].pointer(), Reason: "cannot name schema", Err: err}
+			}
+			err = t.addObjectSchema(val.object, symbol)
+			if err != nil {
+				return err
+			}
+			t.index.update(symbol)
+			t.origins[symbol] = val.paths[0]
+			extraction.Schema = symbol
+			extraction.Reason = reason
+			if candidate != symbol {
+				extraction.Candidate = candidate
 			}
-			s.replaceWithRefs(val.paths, symbol)
 		}
+		if err := t.replaceWithRefs(val.paths, symbol); err != nil {
+			return err
+		}
+		t.extractions = append(t.extractions, extraction)
+	}
+	return nil
+}
+
+// inputPointers returns the pointers to paths in the document given to
+// Transform, rather than in schemas which have been extracted since.
+func (t *transformer) inputPointers(ps paths) []string {
+	ret := make([]string, len(ps))
+	for i, path := range ps {
+		ret[i] = t.originalPath(path).pointer()
+	}
+	return ret
+}
+
+// symbol returns the name for a group of schemas found by e: the name hinted
+// by the schema itself, or else given by the naming template for the
+// location if there is one, or else by the default rules. The name is made a
+// valid identifier.
+func (t *transformer) symbol(e extraction, val objectWithPaths) (string, Reason, error) {
+	symbol, reason, err := t.candidateSymbol(e, val)
+	if err != nil {
+		return "", "", err
+	}
+	identifier := toIdentifier(symbol)
+	if identifier == "" {
+		return "", "", fmt.Errorf("%q is not a valid name", symbol)
+	}
+	return identifier, reason, nil
+}
+
+func (t *transformer) candidateSymbol(e extraction, val objectWithPaths) (string, Reason, error) {
+	if hint := t.nameHint(val.object, val.paths[0]); hint != "" {
+		return hint, ReasonHint, nil
+	}
+	symbol, reason, err := e.symbol(t, val)
+	if err != nil {
+		return "", "", err
+	}
+	if _, ok := t.naming[e.location]; !ok {
+		return symbol, reason, nil
+	}
+	data, err := e.data(t.Spec, val)
+	if err != nil {
+		return "", "", err
+	}
+	data.Default = symbol
+	data.Common = reason != ReasonUnique
+	symbol, err = t.naming.name(e.location, data)
+	return symbol, reason, err
+}
+
+// searchPaths returns a function which finds the inline (non-$ref) objects at
+// each of the given paths.
+func searchPaths(paths ...string) func(s Spec) []objectWithPath {
+	return func(s Spec) []objectWithPath {
+		ret := []objectWithPath{}
+		for _, path := range paths {
+			ret = append(ret, removeRefs(s.findStringPath(path))...)
+		}
+		return ret
+	}
+}
+
+// searchItems returns a function which finds the inline object items of the
+// arrays at each of the given paths.
+func searchItems(paths ...string) func(s Spec) []objectWithPath {
+	itemPaths := make([]string, len(paths))
+	for i, path := range paths {
+		itemPaths[i] = strings.TrimSuffix(path, objectFilter) + ".items" + objectFilter
+	}
+	return searchPaths(itemPaths...)
+}
+
+// withoutArrays returns a function which finds the schemas found by find,
+// except for arrays.
+func withoutArrays(find func(s Spec) []objectWithPath) func(s Spec) []objectWithPath {
+	return func(s Spec) []objectWithPath {
+		return filter(find(s), func(o objectWithPath) bool {
+			return o.object["type"] != "array"
+		})
+	}
+}
+
+// itemExtraction extracts the items of arrays found by e.items, named as the
+// array would be by e followed by Item.
+func (e extraction) itemExtraction() extraction {
+	arrays := func(val objectWithPaths) objectWithPaths {
+		ret := objectWithPaths{object: val.object, paths: make(paths, len(val.paths))}
+		for i, path := range val.paths {
+			ret.paths[i] = path[:len(path)-1]
+		}
+		return ret
+	}
+	return extraction{
+		location:    e.location,
+		description: e.description + " array items",
+		find:        e.items,
+		symbol: func(t *transformer, val objectWithPaths) (string, Reason, error) {
+			symbol, reason, err := e.symbol(t, arrays(val))
+			return symbol + "Item", reason, err
+		},
+		data: func(s Spec, val objectWithPaths) (NameData, error) {
+			data, err := e.data(s, arrays(val))
+			data.Subschema = "Item"
+			return data, err
+		},
+	}
+}
+
+// parameterNames returns the name of the parameter containing each schema
+func (s Spec) parameterNames(ps paths) []string {
+	ret := make([]string, len(ps))
+	for i, path := range ps {
+		idx := indexOf(path, "parameters")
+		if idx < 0 || len(path) < idx+2 {
+			continue
+		}
+		if parameter, ok := s.lookupObject(path[:idx+2]); ok {
+			ret[i], _ = parameter["name"].(string)
+		}
+	}
+	return ret
+}
 
+func indexOf(path _path, key string) int {
+	for i, k := range path {
+		if k == key {
+			return i
+		}
 	}
-	return s
+	return -1
 }
 
-func (s Spec) uniqueSymbol(symbol string) string {
-	for s.symbolExists(symbol) {
-		symbol = nextSymbol(symbol)
+// discriminatorValue returns the value which selects the composition branch at
+// path, if the containing schema has a discriminator and the branch pins the
+// discriminator property to a single value.
+func (s Spec) discriminatorValue(path _path, branch object) string {
+	if len(path) < 2 {
+		return ""
+	}
+	parent, ok := s.lookupObject(path[:len(path)-2])
+	if !ok {
+		return ""
+	}
+	discriminator, ok := parent["discriminator"].(object)
+	if !ok {
+		return ""
+	}
+	propertyName, ok := discriminator["propertyName"].(string)
+	if !ok {
+		return ""
+	}
+	properties, ok := branch["properties"].(object)
+	if !ok {
+		return ""
 	}
-	return symbol
+	property, ok := properties[propertyName].(object)
+	if !ok {
+		return ""
+	}
+	if value, ok := property["const"]; ok {
+		return fmt.Sprintf("%v", value)
+	}
+	if values, ok := property["enum"].([]interface{}); ok && len(values) == 1 {
+		return fmt.Sprintf("%v", values[0])
+	}
+	return ""
 }
 
-func (s Spec) symbolExists(symbol string) bool {
-	_, exists := s.schemasNode()[symbol]
-	return exists
+// uniqueSymbol returns symbol, or the name given by the collision resolver if
+// symbol already exists.
+func (t *transformer) uniqueSymbol(e extraction, val objectWithPaths, symbol string) (string, error) {
+	schemas, err := t.schemasNode()
+	if err != nil {
+		return "", err
+	}
+	exists := func(name string) bool {
+		_, ok := schemas[name]
+		return ok
+	}
+	return resolveCollision(t.opts.CollisionResolver, symbol, func() (NameData, error) {
+		return e.data(t.Spec, val)
+	}, exists)
 }
 
 func removeRefs(in []objectWithPath) []objectWithPath {
-	return filter(in, func(o objectWithPath) bool { 
+	return filter(in, func(o objectWithPath) bool {
 		_, remove := o.object["$ref"]
 		return !remove
 	})
 }
 
 func filter[T any](slice []T, f func(T) bool) []T {
-    var n []T
-    for _, e := range slice {
-        if f(e) {
-            n = append(n, e)
-        }
-    }
-    return n
+	var n []T
+	for _, e := range slice {
+		if f(e) {
+			n = append(n, e)
+		}
+	}
+	return n
 }
 
 func (s Spec) findStringPath(path string) []objectWithPath {
 	return s.findPath(newPath(strings.TrimPrefix(path, "$.")))
 }
 
+// findPath finds the objects matching a search path, in which "" matches at
+// any depth, "*" any key and "[a,b]" either key. Use lookupObject for a
+// literal path.
 func (s Spec) findPath(path _path) []objectWithPath {
 	return s.object.findPath(path, nil)
 }
 
-func (s Spec) schemasNode() object {
-	return s.object.getOrCreateChildObject("components").
-		getOrCreateChildObject("schemas")
-}
-
-func (s Spec) addObjectSchema(obj object, name string) {
-	s.schemasNode()[name] = copyObject(obj)
+// lookupObject returns the object at the literal path, whose keys may be
+// any string at all.
+func (s Spec) lookupObject(path _path) (object, bool) {
+	found, _ := lookupPath(s.object, path)
+	obj, ok := found.(object)
+	return obj, ok
 }
 
-func (s Spec) replaceWithRefs(paths []_path, name string) {
-	for _, path := range paths {
-		s.replaceWithRef(path, name)
+func (s Spec) schemasNode() (object, error) {
+	components, err := s.object.getOrCreateChildObject("components")
+	if err != nil {
+		return nil, &TransformError{Pointer: _path{"components"}.pointer(), Reason: "not an object"}
+	}
+	schemas, err := components.getOrCreateChildObject("schemas")
+	if err != nil {
+		return nil, &TransformError{Pointer: _path{"components", "schemas"}.pointer(), Reason: "not an object"}
 	}
+	return schemas, nil
 }
 
-func (s Spec) replaceWithRef(path _path, name string) {
-	found := s.findPath(path)
-	if len(found) != 1 {
-		panic(fmt.Errorf("expected to find 1 object, found %d", len(found)))
-	}
-	obj := found[0].object
-	// Remove all existing keys
-	for k := range obj {
-		delete(obj, k)
+func (s Spec) addObjectSchema(obj object, name string) error {
+	schemas, err := s.schemasNode()
+	if err != nil {
+		return err
 	}
-	obj["$ref"] = fmt.Sprintf("#/components/schemas/%s", name)
+	schemas[name] = copyObject(obj)
+	return nil
 }
 
-func (s Spec) findMatchingSchema(obj object) string {
-	for name, schema := range s.schemasNode() {
-		schemaObj, ok := schema.(object)
-		if !ok {
-			continue
+// replaceWithRefs replaces the objects at paths with references to the named
+// schema, updating the hashes of the schemas which contained them.
+func (t *transformer) replaceWithRefs(paths []_path, name string) error {
+	for _, path := range paths {
+		if err := t.replaceWithRef(path, name); err != nil {
+			return err
 		}
-		if schemaObj.isEqual(obj) {
-			return fmt.Sprintf("%v", name)
+		t.compare.invalidate(t.object.objectsOnPath(path)...)
+		if len(path) > 2 && path[0] == "components" && path[1] == "schemas" {
+			t.index.update(path[2])
 		}
 	}
-	return ""
-}
-
-func sanitizeURLPath(in string) string {
-	in = strings.Trim(in, "/")
-	in = strings.ReplaceAll(in, "-", "/")
-	vals := strings.Split(in, "/")
-	var sb strings.Builder
-	for _, v := range vals {
-		sb.WriteString(capitalizeFirst(v))
-	}
-	return sb.String()
+	return nil
 }
 
-func nextSymbol(symbol string) string {
-	sym := []rune(symbol)
-	var numDigits int
-	for numDigits = 0; unicode.IsDigit(sym[len(sym)-1-numDigits]); numDigits++ {
+func (s Spec) replaceWithRef(path _path, name string) error {
+	obj, ok := s.lookupObject(path)
+	if !ok {
+		return &TransformError{Pointer: path.pointer(), Reason: "expected to find an object"}
 	}
-	if numDigits == 0 {
-		return symbol + "2"
-	}
-	startIndex := len(sym) - (numDigits)
-	suffix := sym[startIndex:]
-	prefix := sym[:len(sym)-(numDigits)]
-	lastNum, err := strconv.Atoi(string(suffix))
-	if err != nil {
-		panic(err)
+	// Remove all existing keys
+	for k := range obj {
+		delete(obj, k)
 	}
-	return fmt.Sprintf("%s%d", string(prefix), (lastNum + 1))
+	obj["$ref"] = fmt.Sprintf("#/components/schemas/%s", name)
+	return nil
 }
 
-func groupObjects(objects []objectWithPath) []objectWithPaths {
+// groupObjects groups objects with the same canonical form, keeping the
+// first of each group.
+func groupObjects(objects []objectWithPath, canonical func(object) string) []objectWithPaths {
 	ret := []objectWithPaths{}
+	index := map[string]int{}
 	for _, obj := range objects {
-		if idx := findMatchingObjectWithPaths(obj.object, ret); idx >= 0 {
+		key := canonical(obj.object)
+		if idx, ok := index[key]; ok {
 			ret[idx].paths = append(ret[idx].paths, obj.path)
 		} else {
+			index[key] = len(ret)
 			ret = append(ret, objectWithPaths{object: obj.object, paths: []_path{obj.path}})
 		}
 	}
 	return ret
 }
 
-func findMatchingObjectWithPaths(obj object, list []objectWithPaths) int {
-	for i, owp := range list {
-		if owp.object.isEqual(obj) {
-			return i
-		}
-	}
-	return -1
-}
-
 func toTitle(in string) string {
 	return cases.Title(language.English).String(in)
 }
-
-func capitalizeFirst(in string) string {
-	// return cases.Title(language.English).String(in)
-	return strings.ToUpper(in[:1]) + in[1:]
-}
@@ -1,7 +1,9 @@
 package spec
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,7 +18,7 @@ func Test_findPath(t *testing.T) {
 		"default": {
 			path: "$.paths.*.*.requestBody.*.schema",
 			spec: Spec{
-				object{
+				object: object{
 					"paths": object{
 						"foo": object{
 							"ping": object{
@@ -50,7 +52,7 @@ func Test_findPath(t *testing.T) {
 		"specify attribute type": {
 			path: "$.components.schemas.*.[?(@type=='object')]",
 			spec: Spec{
-				object{
+				object: object{
 					"components": object{
 						"schemas": object{
 							"topLevel": object{
@@ -82,7 +84,7 @@ func Test_findPath(t *testing.T) {
 		"one level down specify attribute type": {
 			path: "$.components.schemas.*.*.*.[?(@type=='object')]",
 			spec: Spec{
-				object{
+				object: object{
 					"components": object{
 						"schemas": object{
 							"topLevel": object{
@@ -124,7 +126,7 @@ func Test_findPath(t *testing.T) {
 		"arbitrary depth specify attribute type": {
 			path: "$.components.schemas.*.*..[?(@type=='object')]",
 			spec: Spec{
-				object{
+				object: object{
 					"components": object{
 						"schemas": object{
 							"topLevel": object{
@@ -157,6 +159,84 @@ func Test_findPath(t *testing.T) {
 				},
 			},
 		},
+		"union": {
+			path: "$.paths.*.[get,post].responses",
+			spec: Spec{
+				object: object{
+					"paths": object{
+						"/foo": object{
+							"parameters": object{
+								"responses": object{},
+							},
+							"post": object{
+								"responses": object{},
+							},
+						},
+					},
+				},
+			},
+			want: []objectWithPath{
+				{
+					object: object{},
+					path:   _path{"paths", "/foo", "post", "responses"},
+				},
+			},
+		},
+		"into sequence": {
+			path: "$.components.schemas.*.oneOf.*.[?(@type=='object')]",
+			spec: Spec{
+				object: object{
+					"components": object{
+						"schemas": object{
+							"pet": object{
+								"oneOf": []interface{}{
+									object{
+										"$ref": "#/components/schemas/cat",
+									},
+									object{
+										"type": "object",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: []objectWithPath{
+				{
+					object: object{
+						"type": "object",
+					},
+					path: _path{"components", "schemas", "pet", "oneOf", "1"},
+				},
+			},
+		},
+		"sequence index": {
+			path: "$.components.schemas.pet.oneOf.0",
+			spec: Spec{
+				object: object{
+					"components": object{
+						"schemas": object{
+							"pet": object{
+								"oneOf": []interface{}{
+									object{
+										"type": "object",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: []objectWithPath{
+				{
+					object: object{
+						"type": "object",
+					},
+					path: _path{"components", "schemas", "pet", "oneOf", "0"},
+				},
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -258,61 +338,1339 @@ func Test_groupObjects(t *testing.T) {
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			if got := groupObjects(tt.in); !reflect.DeepEqual(got, tt.want) {
+			if got := groupObjects(tt.in, Comparator{}.canonicalizer(nil).canonical); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("GroupObjects() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func Test_nextSymbol(t *testing.T) {
+func Test_capitalizeFirst(t *testing.T) {
 	tests := map[string]struct {
 		in   string
 		want string
 	}{
-		"no suffix": {
-			in:   "Foo",
-			want: "Foo2",
+		"all lower case": {
+			in:   "foo",
+			want: "Foo",
+		},
+		"already title case": {
+			in:   "FooBar",
+			want: "FooBar",
+		},
+		"camel case": {
+			in:   "fooBar",
+			want: "FooBar",
 		},
-		"suffix 1": {
-			in:   "Foo1",
-			want: "Foo2",
+		"empty": {
+			in:   "",
+			want: "",
 		},
-		"suffix another number": {
-			in:   "Foo999",
-			want: "Foo1000",
+		"unicode": {
+			in:   "élan",
+			want: "Élan",
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			if got := nextSymbol(tt.in); got != tt.want {
-				t.Errorf("nextSymbol() = %v, want %v", got, tt.want)
+			assert.Equal(t, tt.want, capitalizeFirst(tt.in))
+		})
+	}
+}
+
+func TestSpec_Transform_compositions(t *testing.T) {
+	in := `
+components:
+  schemas:
+    Pet:
+      discriminator:
+        propertyName: kind
+      oneOf:
+        - type: object
+          properties:
+            kind:
+              enum: [cat]
+        - type: object
+          properties:
+            kind:
+              type: string
+            bark:
+              type: boolean
+    Owner:
+      type: object
+      properties:
+        address:
+          allOf:
+            - $ref: '#/components/schemas/Base'
+            - type: object
+              properties:
+                street:
+                  type: string
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	out, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	schemas, err := out.schemasNode()
+	assert.NoError(t, err)
+	assert.Equal(t, object{
+		"discriminator": object{"propertyName": "kind"},
+		"oneOf": []interface{}{
+			object{"$ref": "#/components/schemas/PetCat"},
+			object{"$ref": "#/components/schemas/PetVariant2"},
+		},
+	}, schemas["Pet"])
+	assert.Contains(t, schemas, "PetCat")
+	assert.Contains(t, schemas, "PetVariant2")
+	assert.Equal(t, object{"$ref": "#/components/schemas/OwnerAddressPart2"},
+		schemas["Owner"].(object)["properties"].(object)["address"].(object)["allOf"].([]interface{})[1])
+	assert.Contains(t, schemas, "OwnerAddressPart2")
+}
+
+func TestSpec_Transform_subschemas(t *testing.T) {
+	in := `
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        labels:
+          type: object
+          additionalProperties:
+            type: object
+            properties:
+              text: {type: string}
+        grid:
+          type: array
+          items:
+            type: array
+            items:
+              type: object
+              properties:
+                cell: {type: integer}
+      patternProperties:
+        ^x-:
+          type: object
+          properties:
+            vendor: {type: string}
+      not:
+        type: object
+        required: [deleted]
+    Animal:
+      allOf:
+        - oneOf:
+            - type: object
+              properties:
+                legs: {type: integer}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	_, extractions, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	got := map[string]Location{}
+	for _, e := range extractions {
+		got[e.Schema] = e.Location
+	}
+	assert.Equal(t, map[string]Location{
+		"PetLabels":           LocationEmbeddedObject,
+		"PetXValue":           LocationEmbeddedObject,
+		"PetNot":              LocationEmbeddedObject,
+		"PetGridItemItem":     LocationEmbeddedArrayObject,
+		"AnimalPart1Variant1": LocationComposition,
+		"PetLabelsValue":      LocationEmbeddedObject,
+	}, got)
+}
+
+func TestSpec_Transform_literalKeys(t *testing.T) {
+	in := `
+components:
+  schemas:
+    Pet:
+      type: object
+      patternProperties:
+        "[a-z]":
+          type: object
+          properties:
+            vendor: {type: string}
+      properties:
+        "":
+          type: object
+          properties:
+            empty: {type: string}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	transformed, extractions, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	got := map[string][]string{}
+	for _, e := range extractions {
+		got[e.Schema] = e.Pointers
+	}
+	assert.Equal(t, map[string][]string{
+		"PetAZValue":  {"/components/schemas/Pet/patternProperties/[a-z]"},
+		"PetProperty": {"/components/schemas/Pet/properties/"},
+	}, got)
+	pet, _ := lookupPath(transformed.object, _path{"components", "schemas", "Pet"})
+	assert.Equal(t, object{"$ref": "#/components/schemas/PetAZValue"}, pet.(object)["patternProperties"].(object)["[a-z]"])
+	assert.Equal(t, object{"$ref": "#/components/schemas/PetProperty"}, pet.(object)["properties"].(object)[""])
+}
+
+func TestSpec_Transform_arrays(t *testing.T) {
+	in := `
+paths:
+  /pets:
+    get:
+      parameters:
+        - name: filter
+          in: query
+          schema:
+            type: array
+            items:
+              type: object
+              properties:
+                field: {type: string}
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  type: object
+                  properties:
+                    name: {type: string}
+`
+	tests := map[string]struct {
+		opts         Options
+		want         []string
+		wantResponse interface{}
+	}{
+		"default": {
+			want:         []string{"GetPets200Response", "GetPetsFilterParamItem", "GetPets200ResponseItem"},
+			wantResponse: object{"$ref": "#/components/schemas/GetPets200Response"},
+		},
+		"inline arrays": {
+			opts: Options{InlineArrays: true},
+			want: []string{"GetPets200ResponseItem", "GetPetsFilterParamItem"},
+			wantResponse: object{
+				"type":  "array",
+				"items": object{"$ref": "#/components/schemas/GetPets200ResponseItem"},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(in))
+			assert.NoError(t, err)
+			out, extractions, err := s.Transform(tt.opts)
+			assert.NoError(t, err)
+			var got []string
+			for _, e := range extractions {
+				got = append(got, e.Schema)
 			}
+			assert.Equal(t, tt.want, got)
+			found := out.findStringPath("$.paths./pets.get.responses.200.content.application/json.schema")
+			assert.Len(t, found, 1)
+			assert.Equal(t, tt.wantResponse, found[0].object)
 		})
 	}
 }
 
-func Test_capitalizeFirst(t *testing.T) {
+func TestSpec_JSONRoundTrip(t *testing.T) {
+	in := `{
+  "openapi": "3.0.0",
+  "paths": {
+    "/v2/foo": {
+      "post": {
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "count": {"type": "integer", "maximum": 10, "multipleOf": 0.5}
+                  }
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+	s, err := NewFromJSON(strings.NewReader(in))
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, s.ToJSON(&out))
+	assert.JSONEq(t, in, out.String())
+
+	outSpec, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+	var transformed strings.Builder
+	assert.NoError(t, outSpec.ToJSON(&transformed))
+	assert.JSONEq(t, `{
+  "openapi": "3.0.0",
+  "components": {
+    "schemas": {
+      "PostV2Foo200Response": {
+        "type": "object",
+        "properties": {
+          "count": {"type": "integer", "maximum": 10, "multipleOf": 0.5}
+        }
+      }
+    }
+  },
+  "paths": {
+    "/v2/foo": {
+      "post": {
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/PostV2Foo200Response"}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`, transformed.String())
+}
+
+func TestSpec_YamlToJSON(t *testing.T) {
+	s, err := NewFromYaml(strings.NewReader("paths:\n  /foo:\n    get:\n      responses:\n        200:\n          description: ok\n"))
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, s.ToJSON(&out))
+	assert.JSONEq(t, `{"paths": {"/foo": {"get": {"responses": {"200": {"description": "ok"}}}}}}`, out.String())
+}
+
+func TestSpec_ToJSON_numbers(t *testing.T) {
+	in := `{
+  "a": 1.0,
+  "b": 1e10,
+  "c": -0,
+  "d": 12
+}
+`
+	s, err := NewFromJSON(strings.NewReader(in))
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, s.ToJSON(&out))
+	assert.Equal(t, in, out.String())
+
+	// yaml numbers which are not valid JSON are converted
+	s, err = NewFromYaml(strings.NewReader("a: 0x10\nb: +1\n"))
+	assert.NoError(t, err)
+	out.Reset()
+	assert.NoError(t, s.ToJSON(&out))
+	assert.JSONEq(t, `{"a": 16, "b": 1}`, out.String())
+}
+
+func TestSpec_JSONToYaml(t *testing.T) {
+	s, err := NewFromJSON(strings.NewReader(`{"paths": {"/foo": {"get": {"responses": {"200": {"description": "ok"}}}}}}`))
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, s.ToYaml(&out))
+	assert.Equal(t, `paths:
+  /foo:
+    get:
+      responses:
+        "200":
+          description: ok
+`, out.String())
+}
+
+func TestNewFromJSON_escapes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "escaped solidus",
+			in:   `{"info": {"title": "a\/b"}}`,
+			want: "a/b",
+		},
+		{
+			name: "surrogate pair",
+			in:   `{"info": {"title": "\ud83d\ude00"}}`,
+			want: "😀",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewFromJSON(strings.NewReader(tt.in))
+			assert.NoError(t, err)
+			assert.Equal(t, object{"info": object{"title": tt.want}}, s.object)
+
+			var out strings.Builder
+			assert.NoError(t, s.ToJSON(&out))
+			assert.JSONEq(t, tt.in, out.String())
+		})
+	}
+}
+
+func TestNewFromJSON_invalid(t *testing.T) {
+	for _, in := range []string{`{"a": }`, `{"a": 1} {}`, `[1]`} {
+		_, err := NewFromJSON(strings.NewReader(in))
+		assert.Error(t, err, in)
+	}
+}
+
+// aliasBomb returns a "billion laughs" document, in which each level of
+// aliases repeats the one before nine times
+func aliasBomb(levels int) string {
+	var sb strings.Builder
+	sb.WriteString("a0: &a0 [lol, lol, lol, lol, lol, lol, lol, lol, lol]\n")
+	for i := 1; i <= levels; i++ {
+		fmt.Fprintf(&sb, "a%d: &a%d [", i, i)
+		for j := 0; j < 9; j++ {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "*a%d", i-1)
+		}
+		sb.WriteString("]\n")
+	}
+	return sb.String()
+}
+
+func TestNewFromYaml_aliases(t *testing.T) {
 	tests := map[string]struct {
 		in   string
 		want string
 	}{
-		"all lower case": {
-			in:   "foo",
-			want: "Foo",
+		"recursive alias": {
+			in:   "x: &a [*a]\n",
+			want: `anchor "a" value contains itself`,
 		},
-		"already title case": {
-			in:   "FooBar",
-			want: "FooBar",
+		"recursive mapping alias": {
+			in:   "x: &a\n  y: *a\n",
+			want: `anchor "a" value contains itself`,
 		},
-		"camel case": {
-			in:   "fooBar",
-			want: "FooBar",
+		"alias bomb": {
+			in:   aliasBomb(9),
+			want: "excessive aliasing",
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			assert.Equal(t, tt.want, capitalizeFirst(tt.in))
+			_, err := NewFromYaml(strings.NewReader(tt.in))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.want)
+			}
+		})
+	}
+
+	// An alias used many times is expanded each time
+	s, err := NewFromYaml(strings.NewReader(aliasBomb(3)))
+	assert.NoError(t, err)
+	assert.Len(t, s.object["a3"], 9)
+}
+
+func TestSpec_ToYaml_flowParent(t *testing.T) {
+	in := `paths:
+  /n:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                # comment
+                type: object
+                properties:
+                  id: {type: string}
+components: {schemas: {}}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	transformed, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, transformed.ToYaml(&out))
+	assert.Contains(t, out.String(), `components:
+  schemas:
+    GetN200Response:
+      # comment
+      type: object
+      properties:
+        id: {type: string}
+`)
+}
+
+func TestSpec_ToYaml_preservesOrderAndComments(t *testing.T) {
+	in := `# An api
+openapi: 3.0.0
+paths:
+  /v2/foo:
+    post:
+      summary: Create a foo # inline comment
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              # the name
+              properties:
+                name: {type: string}
+components:
+  schemas:
+    Zebra:
+      type: string
+    Apple:
+      type: string
+`
+	want := `# An api
+openapi: 3.0.0
+paths:
+  /v2/foo:
+    post:
+      summary: Create a foo # inline comment
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/PostV2FooRequest'
+components:
+  schemas:
+    Zebra:
+      type: string
+    Apple:
+      type: string
+    PostV2FooRequest:
+      type: object
+      # the name
+      properties:
+        name: {type: string}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+
+	var unchanged strings.Builder
+	assert.NoError(t, s.ToYaml(&unchanged))
+	assert.Equal(t, in, unchanged.String())
+
+	transformed, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+	var out strings.Builder
+	assert.NoError(t, transformed.ToYaml(&out))
+	assert.Equal(t, want, out.String())
+}
+
+func TestSpec_ToYaml_roundTrip(t *testing.T) {
+	in := `openapi: 3.0.0
+info:
+  description: >
+    A folded
+    description
+  summary: |
+    A literal
+    summary
+  x-tags: [a, b,   c]
+paths:
+  /pets:
+    get:
+      summary: x  # c
+      responses:
+        200:
+          description: ok   # trailing
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	transformed, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, transformed.ToYaml(&out))
+	assert.Equal(t, in, out.String())
+}
+
+func TestSpec_ToYaml_keepsUnchangedText(t *testing.T) {
+	in := `# An api
+openapi: 3.0.0
+info:
+  description: >
+    A folded
+    description
+  title: Pets   # trailing
+tags: [a,   b]
+paths:
+  /pets:
+    get:
+      summary: x  # c
+      parameters:
+        - name: filter
+          in: query
+          schema:
+            type: object
+            properties:
+              q: {type: string}
+        -   name: limit
+            in: query   # lim
+            schema: {type: integer}
+      responses:
+        200:
+          description: ok   # trailing
+          content:
+            application/json:
+              # the body
+              schema:
+                type: object
+                properties:
+                  id: {type: string}
+
+components:
+  schemas:
+    Zebra:
+      type: string   # z
+    Pet:
+      allOf:
+        - $ref: '#/components/schemas/Zebra'
+        - type: object
+          properties:
+            name: {type: string}
+`
+	want := `# An api
+openapi: 3.0.0
+info:
+  description: >
+    A folded
+    description
+  title: Pets   # trailing
+tags: [a,   b]
+paths:
+  /pets:
+    get:
+      summary: x  # c
+      parameters:
+        - name: filter
+          in: query
+          schema:
+            $ref: '#/components/schemas/GetPetsFilterParam'
+        -   name: limit
+            in: query   # lim
+            schema: {type: integer}
+      responses:
+        200:
+          description: ok   # trailing
+          content:
+            application/json:
+              # the body
+              schema:
+                $ref: '#/components/schemas/GetPets200Response'
+
+components:
+  schemas:
+    Zebra:
+      type: string   # z
+    Pet:
+      allOf:
+        - $ref: '#/components/schemas/Zebra'
+        - $ref: '#/components/schemas/PetPart2'
+    GetPets200Response:
+      type: object
+      properties:
+        id: {type: string}
+    GetPetsFilterParam:
+      type: object
+      properties:
+        q: {type: string}
+    PetPart2:
+      type: object
+      properties:
+        name: {type: string}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	transformed, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, transformed.ToYaml(&out))
+	assert.Equal(t, want, out.String())
+}
+
+func TestSpec_ToYaml_crlf(t *testing.T) {
+	in := strings.ReplaceAll(`openapi: 3.0.0
+info:
+  description: |
+    Two
+    lines
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+`, "\n", "\r\n")
+	want := strings.ReplaceAll(`openapi: 3.0.0
+info:
+  description: |
+    Two
+    lines
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/PostPetsRequest'
+components:
+  schemas:
+    PostPetsRequest:
+      type: object
+      properties:
+        name: {type: string}
+`, "\n", "\r\n")
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	transformed, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, transformed.ToYaml(&out))
+	assert.Equal(t, want, out.String())
+}
+
+func TestSpec_ToYaml_schemasIndent(t *testing.T) {
+	in := `openapi: 3.0.0
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+components:
+    schemas:
+        Pet:
+            type: object
+            properties:
+                tag:
+                    type: object
+                    properties:
+                        label: {type: string}
+`
+	want := `openapi: 3.0.0
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/PostPetsRequest'
+components:
+    schemas:
+        Pet:
+            type: object
+            properties:
+                tag:
+                    $ref: '#/components/schemas/PetTag'
+        PetTag:
+            type: object
+            properties:
+                label: {type: string}
+        PostPetsRequest:
+            type: object
+            properties:
+                name: {type: string}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	transformed, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, transformed.ToYaml(&out))
+	assert.Equal(t, want, out.String())
+}
+
+func TestSpec_ToYaml_anchors(t *testing.T) {
+	in := `openapi: 3.0.0
+paths:
+  /a:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: &s
+              type: object
+              properties:
+                x: {type: string}
+  /b:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: *s
+`
+	want := `openapi: 3.0.0
+paths:
+  /a:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/CommonPostRequest'
+  /b:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/CommonPostRequest'
+components:
+  schemas:
+    CommonPostRequest: &s
+      type: object
+      properties:
+        x: {type: string}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	transformed, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, transformed.ToYaml(&out))
+	assert.Equal(t, want, out.String())
+	_, err = NewFromYaml(strings.NewReader(out.String()))
+	assert.NoError(t, err)
+}
+
+func TestSpec_Transform_noop(t *testing.T) {
+	tests := map[string]string{
+		"no components": `openapi: 3.0.0
+paths:
+  /foo:
+    get:
+      responses:
+        200:
+          description: ok
+`,
+		"empty components": `openapi: 3.0.0
+paths:
+  /foo:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/responses/Foo'
+components: {}
+`,
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(in))
+			assert.NoError(t, err)
+			transformed, extractions, err := s.Transform(Options{})
+			assert.NoError(t, err)
+			assert.Empty(t, extractions)
+
+			var out strings.Builder
+			assert.NoError(t, transformed.ToYaml(&out))
+			assert.Equal(t, in, out.String())
+		})
+	}
+}
+
+func TestSpec_Transform_deterministic(t *testing.T) {
+	in := `
+paths:
+  /a:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                item: {type: object, properties: {id: {type: string}}}
+                other: {type: object, properties: {id: {type: string}}}
+  /b:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                item: {type: object, properties: {id: {type: integer}}}
+                thing: {type: object, properties: {id: {type: string}}}
+    put:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                item: {type: object, properties: {name: {type: string}}}
+components:
+  schemas:
+    PostARequestItem:
+      type: string
+`
+	var first string
+	for i := 0; i < 50; i++ {
+		s, err := NewFromYaml(strings.NewReader(in))
+		assert.NoError(t, err)
+		transformed, _, err := s.Transform(Options{})
+		assert.NoError(t, err)
+		var out strings.Builder
+		assert.NoError(t, transformed.ToYaml(&out))
+		if i == 0 {
+			first = out.String()
+			continue
+		}
+		if !assert.Equal(t, first, out.String(), "iteration %d", i) {
+			return
+		}
+	}
+}
+
+func TestSpec_Transform_parameterWithoutName(t *testing.T) {
+	for name, param := range map[string]string{"missing": "in: query", "not a string": "name: [a]\n          in: query"} {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(`
+paths:
+  /a:
+    get:
+      parameters:
+        - ` + param + `
+          schema: {type: object, properties: {id: {type: string}}}
+`))
+			assert.NoError(t, err)
+			_, extractions, err := s.Transform(Options{})
+			assert.NoError(t, err)
+			if assert.Len(t, extractions, 1) {
+				assert.Equal(t, "GetAParam", extractions[0].Schema)
+			}
 		})
 	}
 }
+
+func TestSpec_Transform_parametersHeadersAndCallbacks(t *testing.T) {
+	in := `
+paths:
+  /v2/foo:
+    get:
+      parameters:
+        - name: filter
+          in: query
+          schema:
+            type: object
+            properties:
+              status: {type: string}
+        - name: limit
+          in: query
+          schema:
+            type: integer
+      responses:
+        200:
+          description: ok
+          headers:
+            X-Page:
+              schema:
+                type: object
+                properties:
+                  next: {type: string}
+            X-Cursor:
+              content:
+                application/json:
+                  schema:
+                    type: object
+                    properties:
+                      position: {type: string}
+      callbacks:
+        onEvent:
+          '{$request.body#/url}':
+            post:
+              parameters:
+                - name: token
+                  in: header
+                  schema:
+                    type: object
+                    properties:
+                      value: {type: string}
+              requestBody:
+                content:
+                  application/json:
+                    schema:
+                      type: object
+                      properties:
+                        event: {type: string}
+components:
+  parameters:
+    sort:
+      name: sort
+      in: query
+      schema:
+        type: object
+        properties:
+          field: {type: string}
+  requestBodies:
+    NewPet:
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              name: {type: string}
+  responses:
+    NotFound:
+      description: not found
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              message: {type: string}
+  headers:
+    X-Trace:
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              id: {type: string}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	out, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	schemas, err := out.schemasNode()
+	assert.NoError(t, err)
+	for _, name := range []string{
+		"GetV2FooFilterParam",
+		"GetV2Foo200XPageHeader",
+		"GetV2Foo200XCursorHeader",
+		"OnEventPostRequest",
+		"OnEventPostTokenParam",
+		"SortParam",
+		"NewPetRequest",
+		"NotFoundResponse",
+		"XTraceHeader",
+	} {
+		assert.Contains(t, schemas, name)
+	}
+	assert.Len(t, schemas, 9)
+	assert.Equal(t, object{"type": "integer"},
+		out.findPath(_path{"paths", "/v2/foo", "get", "parameters", "1", "schema"})[0].object)
+}
+
+func TestSpec_Transform_pathItems(t *testing.T) {
+	in := `
+paths:
+  /v2/foo:
+    summary: Foo
+    servers:
+      - url: https://example.com
+    parameters:
+      - name: filter
+        in: query
+        schema:
+          type: object
+          properties:
+            status: {type: string}
+    x-extension:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+  /v2/bar:
+    $ref: '#/paths/~1v2~1foo'
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	out, _, err := s.Transform(Options{})
+	assert.NoError(t, err)
+
+	schemas, err := out.schemasNode()
+	assert.NoError(t, err)
+	assert.Contains(t, schemas, "V2FooFilterParam")
+	assert.Contains(t, schemas, "PostV2FooRequest")
+	assert.Len(t, schemas, 2)
+	assert.Equal(t, object{"$ref": "#/paths/~1v2~1foo"}, out.findPath(_path{"paths", "/v2/bar"})[0].object)
+}
+
+func TestSpec_Transform_pathItemRefs(t *testing.T) {
+	in := `openapi: 3.1.0
+paths:
+  /v2/bar:
+    $ref: '#/components/pathItems/Foo'
+  /v2/foo:
+    $ref: '#/components/pathItems/Foo'
+  /v2/missing:
+    $ref: '#/components/pathItems/Missing'
+components:
+  pathItems:
+    Foo:
+      post:
+        requestBody:
+          content:
+            application/json:
+              schema:
+                # the body
+                type: object
+                properties:
+                  name: {type: string}
+`
+	want := `openapi: 3.1.0
+paths:
+  /v2/bar:
+    $ref: '#/components/pathItems/Foo'
+  /v2/foo:
+    $ref: '#/components/pathItems/Foo'
+  /v2/missing:
+    $ref: '#/components/pathItems/Missing'
+components:
+  pathItems:
+    Foo:
+      post:
+        requestBody:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/PostV2BarRequest'
+  schemas:
+    PostV2BarRequest:
+      # the body
+      type: object
+      properties:
+        name: {type: string}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	var log strings.Builder
+	out, extractions, err := s.Transform(Options{Log: &log})
+	assert.NoError(t, err)
+	assert.Equal(t, []Extraction{
+		{
+			Schema:   "PostV2BarRequest",
+			Location: LocationRequestBody,
+			Pointers: []string{"/components/pathItems/Foo/post/requestBody/content/application~1json/schema"},
+			Reason:   ReasonUnique,
+		},
+	}, extractions)
+	assert.Contains(t, log.String(), "Skipping path item /v2/foo which references #/components/pathItems/Foo, already transformed")
+	assert.Contains(t, log.String(), "Skipping path item /v2/missing whose reference #/components/pathItems/Missing is not found")
+
+	var yaml strings.Builder
+	assert.NoError(t, out.ToYaml(&yaml))
+	assert.Equal(t, want, yaml.String())
+}
+
+func TestSpec_Transform_errors(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		path _path
+	}{
+		"schemas not an object": {
+			in:   "components:\n  schemas: []\n",
+			path: _path{"components", "schemas"},
+		},
+		"components not an object": {
+			in:   "components: foo\n",
+			path: _path{"components"},
+		},
+		"empty status code": {
+			in: `
+paths:
+  /a:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema: {type: object, properties: {id: {type: string}}}
+        "":
+          content:
+            application/json:
+              schema: {type: object, properties: {id: {type: string}}}
+`,
+			path: _path{"paths", "/a", "get", "responses", "", "content", "application/json", "schema"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewFromYaml(strings.NewReader(tt.in))
+			assert.NoError(t, err)
+			_, _, err = s.Transform(Options{})
+			var transformErr *TransformError
+			if assert.ErrorAs(t, err, &transformErr) {
+				assert.Equal(t, tt.path.pointer(), transformErr.Pointer)
+			}
+		})
+	}
+
+	// Documents which cannot be read give an error rather than crashing
+	unreadable := map[string]string{
+		"recursive alias": `
+paths:
+  /a:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema: &s {type: object, properties: {self: *s}}
+`,
+		"alias bomb": aliasBomb(9),
+	}
+	for name, in := range unreadable {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewFromYaml(strings.NewReader(in))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSpec_Transform_options(t *testing.T) {
+	in := `
+paths:
+  /v2/foo:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+      responses:
+        200:
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  name: {type: string}
+        201:
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  id: {type: string}
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+	original := copyObject(s.object)
+
+	out, extractions, err := s.Transform(Options{Locations: []Location{LocationResponseBody}})
+	assert.NoError(t, err)
+	assert.Equal(t, original, s.object, "input should not be modified")
+	assert.Equal(t, []Extraction{
+		{
+			Schema:   "PostV2Foo200Response",
+			Location: LocationResponseBody,
+			Pointers: []string{"/paths/~1v2~1foo/post/responses/200/content/application~1json/schema"},
+			Reason:   ReasonUnique,
+		},
+		{
+			Schema:   "PostV2Foo201Response",
+			Location: LocationResponseBody,
+			Pointers: []string{"/paths/~1v2~1foo/post/responses/201/content/application~1json/schema"},
+			Reason:   ReasonUnique,
+		},
+	}, extractions)
+	assert.Equal(t, original["paths"].(object)["/v2/foo"].(object)["post"].(object)["requestBody"],
+		out.object["paths"].(object)["/v2/foo"].(object)["post"].(object)["requestBody"])
+
+	_, extractions, err = s.Transform(Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Extraction{
+		{
+			Schema:   "PostV2FooRequest",
+			Location: LocationRequestBody,
+			Pointers: []string{"/paths/~1v2~1foo/post/requestBody/content/application~1json/schema"},
+			Reason:   ReasonUnique,
+		},
+		{
+			Schema:   "PostV2FooRequest",
+			Location: LocationResponseBody,
+			Pointers: []string{"/paths/~1v2~1foo/post/responses/200/content/application~1json/schema"},
+			Reason:   ReasonReused,
+			Reused:   true,
+		},
+		{
+			Schema:   "PostV2Foo201Response",
+			Location: LocationResponseBody,
+			Pointers: []string{"/paths/~1v2~1foo/post/responses/201/content/application~1json/schema"},
+			Reason:   ReasonUnique,
+		},
+	}, extractions)
+
+	_, _, err = s.Transform(Options{Locations: []Location{"requestbody"}})
+	assert.EqualError(t, err, `search of unknown location "requestbody"`)
+}
+
+func TestSpec_Transform_collision(t *testing.T) {
+	in := `
+paths:
+  /v2/foo:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+components:
+  schemas:
+    PostV2FooRequest:
+      type: string
+`
+	s, err := NewFromYaml(strings.NewReader(in))
+	assert.NoError(t, err)
+
+	_, extractions, err := s.Transform(Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Extraction{
+		{
+			Schema:    "PostV2FooRequest2",
+			Location:  LocationRequestBody,
+			Pointers:  []string{"/paths/~1v2~1foo/post/requestBody/content/application~1json/schema"},
+			Reason:    ReasonUnique,
+			Candidate: "PostV2FooRequest",
+		},
+	}, extractions)
+}
@@ -0,0 +1,208 @@
+package spec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// subschemaNames name the schema under each keyword whose value is a schema
+var subschemaNames = map[string]string{
+	"items":                 "Item",
+	"additionalItems":       "Item",
+	"unevaluatedItems":      "Item",
+	"contains":              "Contains",
+	"additionalProperties":  "Value",
+	"unevaluatedProperties": "Value",
+	"not":                   "Not",
+	"propertyNames":         "Name",
+	"if":                    "If",
+	"then":                  "Then",
+	"else":                  "Else",
+}
+
+// subschemaLocations are the locations of schemas found under keywords other
+// than those of objects
+var subschemaLocations = map[string]Location{
+	"items":            LocationEmbeddedArrayObject,
+	"prefixItems":      LocationEmbeddedArrayObject,
+	"additionalItems":  LocationEmbeddedArrayObject,
+	"unevaluatedItems": LocationEmbeddedArrayObject,
+	"contains":         LocationEmbeddedArrayObject,
+	"allOf":            LocationComposition,
+	"anyOf":            LocationComposition,
+	"oneOf":            LocationComposition,
+}
+
+// findSubschemas returns a function finding the inline objects at location
+// within components.schemas
+func findSubschemas(location Location) func(s Spec) []objectWithPath {
+	return func(s Spec) []objectWithPath {
+		ret := []objectWithPath{}
+		for _, schema := range s.findStringPath("$.components.schemas.*") {
+			walkSubschemas(schema.object, schema.path, func(obj object, path _path, keyword string) {
+				if subschemaLocation(keyword) == location {
+					ret = append(ret, objectWithPath{object: obj, path: path})
+				}
+			})
+		}
+		return ret
+	}
+}
+
+func subschemaLocation(keyword string) Location {
+	if location, ok := subschemaLocations[keyword]; ok {
+		return location
+	}
+	return LocationEmbeddedObject
+}
+
+// walkSubschemas calls visit for each inline object at any depth within
+// schema, found under keyword. Objects within an object are not visited,
+// since they are found again once the object has been extracted.
+func walkSubschemas(schema object, path _path, visit func(obj object, path _path, keyword string)) {
+	for _, k := range schema.sortedKeys() {
+		key := fmt.Sprintf("%v", k)
+		switch val := schema[k]; {
+		case schemaMapKeys[key]:
+			if m, ok := val.(object); ok {
+				for _, name := range m.sortedKeys() {
+					visitSubschema(m[name], path.child(key).child(fmt.Sprintf("%v", name)), key, visit)
+				}
+			}
+		case schemaListKeys[key], key == "items":
+			if list, ok := val.([]interface{}); ok {
+				for i, item := range list {
+					visitSubschema(item, path.child(key).child(strconv.Itoa(i)), key, visit)
+				}
+			} else if schemaKeys[key] {
+				visitSubschema(val, path.child(key), key, visit)
+			}
+		case schemaKeys[key]:
+			visitSubschema(val, path.child(key), key, visit)
+		}
+	}
+}
+
+func visitSubschema(v interface{}, path _path, keyword string, visit func(obj object, path _path, keyword string)) {
+	obj, ok := v.(object)
+	if !ok {
+		return
+	}
+	if obj["type"] == "object" {
+		visit(obj, path, keyword)
+		return
+	}
+	walkSubschemas(obj, path, visit)
+}
+
+// subschemaStep is a keyword on the path from a schema to one of its
+// subschemas, with the property name or index which follows it, if any
+type subschemaStep struct {
+	keyword, key string
+}
+
+// subschemaSteps returns the steps from the schema in components.schemas
+// containing the subschema at path
+func subschemaSteps(path _path) ([]subschemaStep, error) {
+	if len(path) < 4 {
+		return nil, fmt.Errorf("path too short")
+	}
+	var ret []subschemaStep
+	for rest := path[3:]; len(rest) > 0; {
+		keyword := rest[0]
+		keyed := false
+		if len(rest) > 1 {
+			_, err := strconv.Atoi(rest[1])
+			keyed = schemaMapKeys[keyword] || schemaListKeys[keyword] || keyword == "items" && err == nil
+		}
+		switch {
+		case keyed:
+			ret = append(ret, subschemaStep{keyword: keyword, key: rest[1]})
+			rest = rest[2:]
+		case schemaKeys[keyword]:
+			ret = append(ret, subschemaStep{keyword: keyword})
+			rest = rest[1:]
+		default:
+			return nil, fmt.Errorf("unexpected keyword %s", keyword)
+		}
+	}
+	return ret, nil
+}
+
+// subschemaName names a schema nested within a schema in components.schemas
+// by the keywords leading to it, e.g. the items of property tags are TagsItem.
+// A branch of a composition is named by discriminatorValue, if given.
+func subschemaName(path _path, discriminatorValue string) (string, error) {
+	steps, err := subschemaSteps(path)
+	if err != nil {
+		return "", err
+	}
+	if len(steps) == 0 {
+		return "", fmt.Errorf("path too short")
+	}
+	var sb strings.Builder
+	for i, step := range steps {
+		switch {
+		case step.keyword == "patternProperties":
+			sb.WriteString(sanitizeURLPath(step.key) + "Value")
+		case schemaMapKeys[step.keyword]:
+			name := sanitizeURLPath(step.key)
+			if name == "" {
+				name = "Property"
+			}
+			sb.WriteString(name)
+		case (step.keyword == "items" || step.keyword == "prefixItems") && step.key != "":
+			index, err := strconv.Atoi(step.key)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString("Item" + strconv.Itoa(index+1))
+		case schemaListKeys[step.keyword]:
+			value := ""
+			if i == len(steps)-1 {
+				value = discriminatorValue
+			}
+			variant, err := compositionVariant(_path{step.keyword, step.key}, value)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(variant)
+		default:
+			sb.WriteString(subschemaNames[step.keyword])
+		}
+	}
+	return sb.String(), nil
+}
+
+// subschemaProperty returns the name of the property nearest to the
+// subschema at path, if any
+func subschemaProperty(path _path) (string, error) {
+	steps, err := subschemaSteps(path)
+	if err != nil {
+		return "", err
+	}
+	ret := ""
+	for _, step := range steps {
+		if step.keyword == "properties" {
+			ret = step.key
+		}
+	}
+	return ret, nil
+}
+
+// isCompositionBranch reports whether the subschema at path is a branch of
+// an allOf, anyOf or oneOf
+func isCompositionBranch(path _path) bool {
+	steps, err := subschemaSteps(path)
+	return err == nil && len(steps) > 0 && subschemaLocation(steps[len(steps)-1].keyword) == LocationComposition
+}
+
+// subschemaDiscriminatorValue returns the discriminator value of the
+// subschema at path, if it is a branch of a composition
+func (s Spec) subschemaDiscriminatorValue(path _path, obj object) string {
+	if !isCompositionBranch(path) {
+		return ""
+	}
+	return s.discriminatorValue(path, obj)
+}
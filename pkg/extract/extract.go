@@ -0,0 +1,377 @@
+// Package extract moves the inline schemas of an openapi 3.x document into
+// components.schemas, so that code generators produce a named type for each.
+package extract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirockin/openapi-extract-schema/internal/spec"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Options controls the behaviour of Transform
+	Options = spec.Options
+	// Location identifies a kind of place in a document where inline schemas
+	// are found
+	Location = spec.Location
+	// Extraction describes a schema which has been moved to components.schemas
+	Extraction = spec.Extraction
+	// Reason describes why the name of an extracted schema was chosen
+	Reason = spec.Reason
+	// NameData is the data available to a naming template
+	NameData = spec.NameData
+	// CollisionResolver chooses a new name when a name is already taken
+	CollisionResolver = spec.CollisionResolver
+	// ContextResolver qualifies a taken name with the context of the schema,
+	// falling back to NumberResolver
+	ContextResolver = spec.ContextResolver
+	// NumberResolver appends a number to a taken name
+	NumberResolver = spec.NumberResolver
+	// Comparator decides whether two schemas are the same
+	Comparator = spec.Comparator
+	// Policy limits which schemas are extracted at a location
+	Policy = spec.Policy
+	// Bundled describes a schema from another file added to
+	// components.schemas by Bundle
+	Bundled = spec.Bundled
+	// InlineOptions controls the behaviour of Inline
+	InlineOptions = spec.InlineOptions
+	// Inlining describes a schema which has been moved from
+	// components.schemas to where it was referenced
+	Inlining = spec.Inlining
+	// PruneOptions controls the behaviour of Prune
+	PruneOptions = spec.PruneOptions
+	// DedupeOptions controls the behaviour of Dedupe
+	DedupeOptions = spec.DedupeOptions
+	// CanonicalRule chooses which of a group of identical schemas is kept by
+	// Dedupe
+	CanonicalRule = spec.CanonicalRule
+	// Duplicate describes a schema replaced by an identical schema by Dedupe
+	Duplicate = spec.Duplicate
+	// TransformError reports a part of the document which could not be
+	// transformed
+	TransformError = spec.TransformError
+)
+
+const (
+	LocationRequestBody          = spec.LocationRequestBody
+	LocationResponseBody         = spec.LocationResponseBody
+	LocationParameter            = spec.LocationParameter
+	LocationPathParameter        = spec.LocationPathParameter
+	LocationResponseHeader       = spec.LocationResponseHeader
+	LocationCallbackRequestBody  = spec.LocationCallbackRequestBody
+	LocationCallbackResponseBody = spec.LocationCallbackResponseBody
+	LocationCallbackParameter    = spec.LocationCallbackParameter
+	LocationComponentParameter   = spec.LocationComponentParameter
+	LocationComponentRequestBody = spec.LocationComponentRequestBody
+	LocationComponentResponse    = spec.LocationComponentResponse
+	LocationComponentHeader      = spec.LocationComponentHeader
+	LocationEmbeddedObject       = spec.LocationEmbeddedObject
+	LocationEmbeddedArrayObject  = spec.LocationEmbeddedArrayObject
+	LocationComposition          = spec.LocationComposition
+)
+
+const (
+	CanonicalShortest       = spec.CanonicalShortest
+	CanonicalFirst          = spec.CanonicalFirst
+	CanonicalMostReferenced = spec.CanonicalMostReferenced
+)
+
+const (
+	ReasonUnique       = spec.ReasonUnique
+	ReasonCommonByVerb = spec.ReasonCommonByVerb
+	ReasonCommonByPath = spec.ReasonCommonByPath
+	ReasonCommon       = spec.ReasonCommon
+	ReasonHint         = spec.ReasonHint
+	ReasonReused       = spec.ReasonReused
+)
+
+// Format is the serialization of a document
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// FormatFromFileName returns the format implied by the extension of a file
+// name, or "" if the extension is not recognised.
+func FormatFromFileName(fileName string) Format {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return ""
+}
+
+// FormatFromContent sniffs the format of a document. Since JSON is also valid
+// yaml, anything which is not a JSON object is treated as yaml.
+func FormatFromContent(content []byte) Format {
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) && json.Valid(content) {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// Config is the content of a configuration file, e.g.
+//
+//	useOperationId: true
+//	nameHints: [x-go-name, title]
+//	collisions: numbers
+//	compare:
+//	  ignore: [description, example]
+//	  resolveRefs: true
+//	naming:
+//	  requestBody: "{{if .OperationID}}{{.OperationID}}Body{{else}}{{.Default}}{{end}}"
+//	  embeddedObject: "{{.Parent}}{{.Property}}"
+//	policies:
+//	  requestBody: {objectsOnly: true}
+//	  embeddedObject: {minProperties: 2}
+//	dedupe:
+//	  canonical: mostReferenced
+type Config struct {
+	// UseOperationID names request and response bodies by the operationId
+	// of their operation
+	UseOperationID bool `yaml:"useOperationId"`
+	// NameHints are the keys of a schema which give its name, in order of
+	// preference. The default is used if not set, and none if empty.
+	NameHints []string `yaml:"nameHints"`
+	// InlineArrays leaves request and response bodies which are arrays
+	// inline, extracting only their items
+	InlineArrays bool `yaml:"inlineArrays"`
+	// Collisions is the strategy used when a name is already taken: context
+	// (the default) or numbers
+	Collisions string `yaml:"collisions"`
+	// Compare controls when schemas are considered the same
+	Compare struct {
+		// Ignore are keywords ignored when comparing schemas
+		Ignore []string `yaml:"ignore"`
+		// Unordered are keywords whose values are compared without regard to
+		// order. The default is used if not set, and none if empty.
+		Unordered []string `yaml:"unordered"`
+		// ResolveRefs compares references as the schema they refer to
+		ResolveRefs bool `yaml:"resolveRefs"`
+	} `yaml:"compare"`
+	// Naming gives a text/template, executed with NameData, for the names of
+	// schemas found at each location
+	Naming map[Location]string `yaml:"naming"`
+	// Policies limit which schemas are extracted at each location
+	Policies map[Location]struct {
+		// ObjectsOnly extracts only objects with properties
+		ObjectsOnly bool `yaml:"objectsOnly"`
+		// MinProperties skips objects with fewer properties
+		MinProperties int `yaml:"minProperties"`
+		// SkipRefArrays skips arrays whose items are a reference
+		SkipRefArrays bool `yaml:"skipRefArrays"`
+	} `yaml:"policies"`
+	// Dedupe controls the deduplication of existing schemas
+	Dedupe struct {
+		// Canonical chooses which of a group of identical schemas is kept:
+		// shortest (the default), first or mostReferenced
+		Canonical CanonicalRule `yaml:"canonical"`
+	} `yaml:"dedupe"`
+}
+
+// ReadConfig reads a yaml configuration file. Unknown keys are an error.
+func ReadConfig(reader io.Reader) (Config, error) {
+	var ret Config
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+	err := decoder.Decode(&ret)
+	if err == io.EOF {
+		return ret, nil
+	}
+	if err != nil {
+		return ret, err
+	}
+	switch ret.Collisions {
+	case "", "context", "numbers":
+	default:
+		return ret, fmt.Errorf("unknown collisions strategy %q", ret.Collisions)
+	}
+	switch ret.Dedupe.Canonical {
+	case "", CanonicalShortest, CanonicalFirst, CanonicalMostReferenced:
+	default:
+		return ret, fmt.Errorf("unknown canonical rule %q", ret.Dedupe.Canonical)
+	}
+	return ret, nil
+}
+
+// Options returns the options given by the configuration.
+func (c Config) Options() Options {
+	ret := Options{
+		UseOperationID: c.UseOperationID,
+		NameHintKeys:   c.NameHints,
+		InlineArrays:   c.InlineArrays,
+		Naming:         c.Naming,
+		Comparator:     c.comparator(),
+	}
+	if c.Collisions == "numbers" {
+		ret.CollisionResolver = NumberResolver{}
+	}
+	if c.Policies != nil {
+		ret.Policies = map[Location]Policy{}
+		for location, policy := range c.Policies {
+			ret.Policies[location] = Policy(policy)
+		}
+	}
+	return ret
+}
+
+// DedupeOptions returns the options for Dedupe given by the configuration.
+func (c Config) DedupeOptions() DedupeOptions {
+	return DedupeOptions{Comparator: c.comparator(), Canonical: c.Dedupe.Canonical}
+}
+
+func (c Config) comparator() Comparator {
+	return Comparator{
+		IgnoreKeys:    c.Compare.Ignore,
+		UnorderedKeys: c.Compare.Unordered,
+		ResolveRefs:   c.Compare.ResolveRefs,
+	}
+}
+
+// Document is an openapi document. Key order, comments and styles of the
+// original are preserved on output.
+type Document struct {
+	spec *spec.Spec
+}
+
+// Read reads a document in the given format, or detects the format from the
+// content if format is "".
+func Read(reader io.Reader, format Format) (*Document, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = FormatFromContent(content)
+	}
+	var s *spec.Spec
+	switch format {
+	case FormatYAML:
+		s, err = spec.NewFromYaml(bytes.NewReader(content))
+	case FormatJSON:
+		s, err = spec.NewFromJSON(bytes.NewReader(content))
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Document{spec: s}, nil
+}
+
+// Write writes the document in the given format.
+func (d *Document) Write(writer io.Writer, format Format) error {
+	switch format {
+	case FormatYAML:
+		return d.spec.ToYaml(writer)
+	case FormatJSON:
+		return d.spec.ToJSON(writer)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// Result is the outcome of Transform
+type Result struct {
+	// Document is the transformed document
+	Document *Document
+	// Extractions describes each schema moved to components.schemas, in the
+	// order in which they were made
+	Extractions []Extraction
+	// input is the document before Transform
+	input *Document
+}
+
+// WriteReport writes the extractions as a JSON report, for review of naming
+// changes.
+func (r Result) WriteReport(writer io.Writer) error {
+	report := struct {
+		Extractions []Extraction `json:"extractions"`
+	}{Extractions: r.Extractions}
+	if report.Extractions == nil {
+		report.Extractions = []Extraction{}
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// Changed reports whether Transform made any change to the document, so that
+// writing it would not reproduce the input.
+func (r Result) Changed() bool {
+	if len(r.Extractions) > 0 {
+		return true
+	}
+	return r.input != nil && !r.input.spec.Equal(*r.Document.spec)
+}
+
+// WriteDiff writes a summary of the changes made by Transform: a '+' line for
+// each schema added to components.schemas and a '~' line for each inline
+// schema replaced by a reference.
+func (r Result) WriteDiff(writer io.Writer) error {
+	var buf bytes.Buffer
+	for _, e := range r.Extractions {
+		ref := "#/components/schemas/" + e.Schema
+		if !e.Reused {
+			fmt.Fprintf(&buf, "+ %s (%s)\n", ref, e.Location)
+		}
+		for _, pointer := range e.Pointers {
+			fmt.Fprintf(&buf, "~ #%s -> %s\n", pointer, ref)
+		}
+	}
+	_, err := writer.Write(buf.Bytes())
+	return err
+}
+
+// Transform moves the inline schemas of doc to components.schemas, replacing
+// them with references. doc itself is not modified.
+func Transform(doc *Document, opts Options) (Result, error) {
+	out, extractions, err := doc.spec.Transform(opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Document: &Document{spec: &out}, Extractions: extractions, input: doc}, nil
+}
+
+// Inline is the inverse of Transform: it moves schemas of components.schemas
+// which are referenced once, or those given in opts, to where they are
+// referenced. doc itself is not modified.
+func Inline(doc *Document, opts InlineOptions) (*Document, []Inlining, error) {
+	out, inlinings, err := doc.spec.Inline(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Document{spec: &out}, inlinings, nil
+}
+
+// Prune removes the schemas of components.schemas which are not referenced,
+// directly or through other schemas, from elsewhere in the document, returning
+// the names of those removed. doc itself is not modified.
+func Prune(doc *Document, opts PruneOptions) (*Document, []string, error) {
+	out, removed, err := doc.spec.Prune(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Document{spec: &out}, removed, nil
+}
+
+// Dedupe replaces the schemas of components.schemas which are the same as
+// another with references to a single schema of each group, and removes them.
+// doc itself is not modified.
+func Dedupe(doc *Document, opts DedupeOptions) (*Document, []Duplicate, error) {
+	out, duplicates, err := doc.spec.Dedupe(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Document{spec: &out}, duplicates, nil
+}
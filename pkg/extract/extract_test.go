@@ -0,0 +1,280 @@
+package extract_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sirockin/openapi-extract-schema/pkg/extract"
+)
+
+func TestTransform(t *testing.T) {
+	in := `openapi: 3.0.0
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+`
+	doc, err := extract.Read(strings.NewReader(in), "")
+	assert.NoError(t, err)
+
+	result, err := extract.Transform(doc, extract.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []extract.Extraction{
+		{
+			Schema:   "PostPetsRequest",
+			Location: extract.LocationRequestBody,
+			Pointers: []string{"/paths/~1pets/post/requestBody/content/application~1json/schema"},
+			Reason:   extract.ReasonUnique,
+		},
+	}, result.Extractions)
+
+	var out strings.Builder
+	assert.NoError(t, result.Document.Write(&out, extract.FormatYAML))
+	assert.Equal(t, `openapi: 3.0.0
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/PostPetsRequest'
+components:
+  schemas:
+    PostPetsRequest:
+      type: object
+      properties:
+        name: {type: string}
+`, out.String())
+
+	var unchanged strings.Builder
+	assert.NoError(t, doc.Write(&unchanged, extract.FormatYAML))
+	assert.Equal(t, in, unchanged.String())
+
+	inlined, inlinings, err := extract.Inline(result.Document, extract.InlineOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []extract.Inlining{
+		{Schema: "PostPetsRequest", Pointers: []string{"/paths/~1pets/post/requestBody/content/application~1json/schema"}},
+	}, inlinings)
+	out.Reset()
+	assert.NoError(t, inlined.Write(&out, extract.FormatYAML))
+	assert.Equal(t, in, out.String())
+}
+
+func TestPrune(t *testing.T) {
+	doc, err := extract.Read(strings.NewReader(`{"components": {"schemas": {"Pet": {"type": "object"}, "Tag": {"type": "string"}}}}`), "")
+	assert.NoError(t, err)
+
+	pruned, removed, err := extract.Prune(doc, extract.PruneOptions{Keep: []string{"Pet"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Tag"}, removed)
+	var out strings.Builder
+	assert.NoError(t, pruned.Write(&out, extract.FormatJSON))
+	assert.JSONEq(t, `{"components": {"schemas": {"Pet": {"type": "object"}}}}`, out.String())
+}
+
+func TestDedupe(t *testing.T) {
+	doc, err := extract.Read(strings.NewReader(`{"components": {"schemas": {"Pet": {"type": "object"}, "PetModel": {"type": "object"}}}}`), "")
+	assert.NoError(t, err)
+
+	deduped, duplicates, err := extract.Dedupe(doc, extract.DedupeOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []extract.Duplicate{{Schema: "PetModel", ReplacedBy: "Pet"}}, duplicates)
+	var out strings.Builder
+	assert.NoError(t, deduped.Write(&out, extract.FormatJSON))
+	assert.JSONEq(t, `{"components": {"schemas": {"Pet": {"type": "object"}}}}`, out.String())
+}
+
+func TestTransform_error(t *testing.T) {
+	doc, err := extract.Read(strings.NewReader(`{"components": []}`), "")
+	assert.NoError(t, err)
+
+	_, err = extract.Transform(doc, extract.Options{})
+	var transformErr *extract.TransformError
+	if assert.ErrorAs(t, err, &transformErr) {
+		assert.Equal(t, "/components", transformErr.Pointer)
+	}
+}
+
+func TestFormatFromContent(t *testing.T) {
+	assert.Equal(t, extract.FormatJSON, extract.FormatFromContent([]byte(` {"openapi": "3.0.0"}`)))
+	assert.Equal(t, extract.FormatYAML, extract.FormatFromContent([]byte(`{openapi: 3.0.0}`)))
+	assert.Equal(t, extract.FormatYAML, extract.FormatFromContent([]byte("openapi: 3.0.0\n")))
+}
+
+func TestResult_WriteReport(t *testing.T) {
+	result := extract.Result{Extractions: []extract.Extraction{
+		{
+			Schema:    "PostPetsRequest2",
+			Location:  extract.LocationRequestBody,
+			Pointers:  []string{"/paths/~1pets/post/requestBody/content/application~1json/schema"},
+			Reason:    extract.ReasonUnique,
+			Candidate: "PostPetsRequest",
+		},
+		{
+			Schema:   "Pet",
+			Location: extract.LocationResponseBody,
+			Pointers: []string{"/paths/~1pets/post/responses/200/content/application~1json/schema"},
+			Reason:   extract.ReasonReused,
+			Reused:   true,
+		},
+	}}
+
+	var out strings.Builder
+	assert.NoError(t, result.WriteReport(&out))
+	assert.JSONEq(t, `{"extractions": [
+  {
+    "schema": "PostPetsRequest2",
+    "location": "requestBody",
+    "pointers": ["/paths/~1pets/post/requestBody/content/application~1json/schema"],
+    "reason": "unique",
+    "candidate": "PostPetsRequest"
+  },
+  {
+    "schema": "Pet",
+    "location": "responseBody",
+    "pointers": ["/paths/~1pets/post/responses/200/content/application~1json/schema"],
+    "reason": "reused",
+    "reused": true
+  }
+]}`, out.String())
+
+	// Schemas extracted from within others are reported where they were in
+	// the input
+	doc, err := extract.Read(strings.NewReader(`openapi: 3.0.0
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                pet:
+                  type: object
+                  properties:
+                    name: {type: string}
+`), "")
+	assert.NoError(t, err)
+	result, err = extract.Transform(doc, extract.Options{})
+	assert.NoError(t, err)
+	out.Reset()
+	assert.NoError(t, result.WriteReport(&out))
+	assert.JSONEq(t, `{"extractions": [
+  {
+    "schema": "PostPetsRequest",
+    "location": "requestBody",
+    "pointers": ["/paths/~1pets/post/requestBody/content/application~1json/schema"],
+    "reason": "unique"
+  },
+  {
+    "schema": "PostPetsRequestPet",
+    "location": "embeddedObject",
+    "pointers": ["/paths/~1pets/post/requestBody/content/application~1json/schema/properties/pet"],
+    "reason": "unique"
+  }
+]}`, out.String())
+}
+
+func TestResult_WriteDiff(t *testing.T) {
+	result := extract.Result{Extractions: []extract.Extraction{
+		{
+			Schema:   "PostPetsRequest",
+			Location: extract.LocationRequestBody,
+			Pointers: []string{"/paths/~1pets/post/requestBody/content/application~1json/schema"},
+		},
+		{
+			Schema:   "Pet",
+			Location: extract.LocationResponseBody,
+			Pointers: []string{"/paths/~1pets/post/responses/200/content/application~1json/schema"},
+			Reused:   true,
+		},
+	}}
+	assert.True(t, result.Changed())
+
+	var out strings.Builder
+	assert.NoError(t, result.WriteDiff(&out))
+	assert.Equal(t, `+ #/components/schemas/PostPetsRequest (requestBody)
+~ #/paths/~1pets/post/requestBody/content/application~1json/schema -> #/components/schemas/PostPetsRequest
+~ #/paths/~1pets/post/responses/200/content/application~1json/schema -> #/components/schemas/Pet
+`, out.String())
+
+	assert.False(t, extract.Result{}.Changed())
+}
+
+func TestReadConfig(t *testing.T) {
+	config, err := extract.ReadConfig(strings.NewReader(`
+useOperationId: true
+inlineArrays: true
+naming:
+  requestBody: "{{.OperationID}}Body"
+  embeddedObject: "{{.Parent}}{{.Property}}"
+policies:
+  requestBody: {objectsOnly: true, skipRefArrays: true}
+  embeddedObject: {minProperties: 2}
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[extract.Location]string{
+		extract.LocationRequestBody:    "{{.OperationID}}Body",
+		extract.LocationEmbeddedObject: "{{.Parent}}{{.Property}}",
+	}, config.Options().Naming)
+	assert.True(t, config.Options().UseOperationID)
+	assert.True(t, config.Options().InlineArrays)
+	assert.Equal(t, map[extract.Location]extract.Policy{
+		extract.LocationRequestBody:    {ObjectsOnly: true, SkipRefArrays: true},
+		extract.LocationEmbeddedObject: {MinProperties: 2},
+	}, config.Options().Policies)
+	assert.Nil(t, config.Options().NameHintKeys)
+	assert.Nil(t, config.Options().CollisionResolver)
+
+	config, err = extract.ReadConfig(strings.NewReader("nameHints: []\n"))
+	assert.NoError(t, err)
+	assert.NotNil(t, config.Options().NameHintKeys)
+	assert.Empty(t, config.Options().NameHintKeys)
+
+	config, err = extract.ReadConfig(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Equal(t, extract.Config{}, config)
+
+	config, err = extract.ReadConfig(strings.NewReader(`
+compare:
+  ignore: [description, example]
+  unordered: []
+  resolveRefs: true
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, extract.Comparator{
+		IgnoreKeys:    []string{"description", "example"},
+		UnorderedKeys: []string{},
+		ResolveRefs:   true,
+	}, config.Options().Comparator)
+
+	config, err = extract.ReadConfig(strings.NewReader("collisions: numbers\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, extract.NumberResolver{}, config.Options().CollisionResolver)
+
+	_, err = extract.ReadConfig(strings.NewReader("collisions: random\n"))
+	assert.EqualError(t, err, `unknown collisions strategy "random"`)
+
+	config, err = extract.ReadConfig(strings.NewReader("compare: {resolveRefs: true}\ndedupe: {canonical: mostReferenced}\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, extract.DedupeOptions{
+		Comparator: extract.Comparator{ResolveRefs: true},
+		Canonical:  extract.CanonicalMostReferenced,
+	}, config.DedupeOptions())
+
+	_, err = extract.ReadConfig(strings.NewReader("dedupe: {canonical: longest}\n"))
+	assert.EqualError(t, err, `unknown canonical rule "longest"`)
+
+	_, err = extract.ReadConfig(strings.NewReader("namings: {}\n"))
+	assert.ErrorContains(t, err, "field namings not found")
+}
@@ -0,0 +1,119 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirockin/openapi-extract-schema/internal/spec"
+)
+
+// Files is a document split across files joined by relative references, such
+// as $ref: schemas/pet.yaml
+type Files struct {
+	set     *spec.FileSet
+	formats map[string]Format
+}
+
+// ReadFiles reads the document in the named file, and every file it refers to
+// with a relative reference. The format of each file is detected from its
+// name, or else from its content. Schemas from other files whose names are
+// taken are renamed by opts.CollisionResolver.
+func ReadFiles(name string, opts Options) (*Files, error) {
+	ret := &Files{formats: map[string]Format{}}
+	set, err := spec.LoadFiles(name, func(name string) (*spec.Spec, error) {
+		content, err := os.ReadFile(filepath.FromSlash(name))
+		if err != nil {
+			return nil, err
+		}
+		format := FormatFromFileName(name)
+		if format == "" {
+			format = FormatFromContent(content)
+		}
+		doc, err := Read(bytes.NewReader(content), format)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		ret.formats[name] = format
+		return doc.spec, nil
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	ret.set = set
+	return ret, nil
+}
+
+// Names returns the names of the files read, the named file first
+func (f *Files) Names() []string {
+	return f.set.Files()
+}
+
+// Format returns the format of the named file
+func (f *Files) Format(name string) Format {
+	return f.formats[name]
+}
+
+// Document returns the files as a single document, in which schemas from other
+// files are added to components.schemas and other referenced content is
+// inline. Writing it gives a bundle of the files.
+func (f *Files) Document() *Document {
+	merged := f.set.Merged
+	return &Document{spec: &merged}
+}
+
+// Bundle returns the files as a single document, in which every schema from
+// another file is in components.schemas and referenced by a local pointer.
+// Schemas which opts.Comparator considers the same as another are replaced by
+// references to it.
+func (f *Files) Bundle(opts Options) (*Document, []Bundled) {
+	s, bundled := f.set.Bundle(opts)
+	return &Document{spec: &s}, bundled
+}
+
+// Split returns each file changed in doc, a transformed copy of Document, by
+// name. Schemas extracted from any file are added to the named file.
+func (f *Files) Split(doc *Document) (map[string]*Document, error) {
+	split, err := f.set.Split(*doc.spec)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]*Document, len(split))
+	for name, s := range split {
+		ret[name] = &Document{spec: s}
+	}
+	return ret, nil
+}
+
+// Write writes each file changed in doc, a transformed copy of Document, back
+// to where it was read from in its original format, returning the names of
+// the files written.
+func (f *Files) Write(doc *Document) ([]string, error) {
+	split, err := f.Split(doc)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(split))
+	for name := range split {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// Only write once every file has been produced, so that a failure does
+	// not leave the files inconsistent
+	contents := make([][]byte, len(names))
+	for i, name := range names {
+		var out bytes.Buffer
+		if err := split[name].Write(&out, f.formats[name]); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", name, err)
+		}
+		contents[i] = out.Bytes()
+	}
+	for i, name := range names {
+		if err := os.WriteFile(filepath.FromSlash(name), contents[i], 0o644); err != nil {
+			return names[:i], err
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,82 @@
+package extract_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sirockin/openapi-extract-schema/pkg/extract"
+)
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"openapi.yaml": `openapi: 3.0.0
+paths:
+  /pets:
+    $ref: paths/pets.json
+`,
+		"paths/pets.json": `{
+  "post": {
+    "requestBody": {
+      "content": {
+        "application/json": {
+          "schema": {"type": "object", "properties": {"name": {"type": "string"}}}
+        }
+      }
+    }
+  }
+}
+`,
+	}
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	root := filepath.ToSlash(filepath.Join(dir, "openapi.yaml"))
+
+	f, err := extract.ReadFiles(root, extract.Options{})
+	assert.NoError(t, err)
+	assert.Len(t, f.Names(), 2)
+	result, err := extract.Transform(f.Document(), extract.Options{})
+	assert.NoError(t, err)
+
+	bundled, list := f.Bundle(extract.Options{})
+	assert.Empty(t, list)
+	var out strings.Builder
+	assert.NoError(t, bundled.Write(&out, extract.FormatYAML))
+	// Content read as JSON is written as block yaml
+	assert.Contains(t, out.String(), "  /pets:\n    post:\n")
+	assert.NotContains(t, out.String(), "components")
+
+	var bundle strings.Builder
+	assert.NoError(t, result.Document.Write(&bundle, extract.FormatYAML))
+	assert.Contains(t, bundle.String(), "$ref: '#/components/schemas/PostPetsRequest'")
+
+	written, err := f.Write(result.Document)
+	assert.NoError(t, err)
+	assert.Len(t, written, 2)
+	content, err := os.ReadFile(filepath.Join(dir, "paths", "pets.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "post": {
+    "requestBody": {
+      "content": {
+        "application/json": {
+          "schema": {
+            "$ref": "../openapi.yaml#/components/schemas/PostPetsRequest"
+          }
+        }
+      }
+    }
+  }
+}
+`, string(content))
+	content, err = os.ReadFile(filepath.Join(dir, "openapi.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "    $ref: paths/pets.json\n")
+	assert.Contains(t, string(content), "    PostPetsRequest:\n      type: object\n")
+}